@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pvc-protection-bench/pkg/stats"
+)
+
+const exitCodeAssertionFailed = 2
+
+type Assertion struct {
+	Expr   string
+	Metric string
+	Op     string
+	Value  string
+}
+
+type AssertionResult struct {
+	Assertion Assertion
+	Actual    string
+	Passed    bool
+	Err       error
+}
+
+type assertionFailedError struct {
+	Failed int
+	Total  int
+}
+
+func (e *assertionFailedError) Error() string {
+	return fmt.Sprintf("%d of %d assertions failed", e.Failed, e.Total)
+}
+
+var assertionOps = []string{"<=", ">=", "==", "<", ">", "="}
+
+var durationMetrics = map[string]bool{
+	"avg":    true,
+	"min":    true,
+	"max":    true,
+	"stddev": true,
+	"p50":    true,
+	"p90":    true,
+	"p99":    true,
+	"p99.9":  true,
+}
+
+func parseAssertion(expr string) (Assertion, error) {
+	trimmed := strings.TrimSpace(expr)
+	for _, op := range assertionOps {
+		idx := strings.Index(trimmed, op)
+		if idx <= 0 {
+			continue
+		}
+		a := Assertion{
+			Expr:   trimmed,
+			Metric: strings.ToLower(strings.TrimSpace(trimmed[:idx])),
+			Op:     op,
+			Value:  strings.TrimSpace(trimmed[idx+len(op):]),
+		}
+		if a.Op == "=" {
+			a.Op = "=="
+		}
+		if a.Value == "" {
+			return Assertion{}, fmt.Errorf("assertion %q: missing value", expr)
+		}
+		switch {
+		case a.Metric == "count":
+			if a.Value != "replicas" {
+				if _, err := strconv.Atoi(a.Value); err != nil {
+					return Assertion{}, fmt.Errorf("assertion %q: count must be an integer or \"replicas\"", expr)
+				}
+			}
+		case durationMetrics[a.Metric]:
+			if _, err := time.ParseDuration(a.Value); err != nil {
+				return Assertion{}, fmt.Errorf("assertion %q: invalid duration %q", expr, a.Value)
+			}
+		default:
+			return Assertion{}, fmt.Errorf("assertion %q: unknown metric %q", expr, a.Metric)
+		}
+		return a, nil
+	}
+	return Assertion{}, fmt.Errorf("assertion %q: expected <metric><op><value>, e.g. p99<=5s", expr)
+}
+
+func parseAssertions(exprs []string) ([]Assertion, error) {
+	assertions := make([]Assertion, 0, len(exprs))
+	for _, expr := range exprs {
+		a, err := parseAssertion(expr)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, a)
+	}
+	return assertions, nil
+}
+
+func evaluateAssertions(assertions []Assertion, latencies []time.Duration, replicas int32) []AssertionResult {
+	summary := stats.Summarize(latencies)
+
+	results := make([]AssertionResult, 0, len(assertions))
+	for _, a := range assertions {
+		result := AssertionResult{Assertion: a}
+		if a.Metric == "count" {
+			expected := int64(replicas)
+			if a.Value != "replicas" {
+				v, _ := strconv.Atoi(a.Value)
+				expected = int64(v)
+			}
+			result.Actual = fmt.Sprintf("%d", summary.Count)
+			result.Passed = compare(int64(summary.Count), a.Op, expected)
+			results = append(results, result)
+			continue
+		}
+
+		if summary.Count == 0 {
+			result.Err = fmt.Errorf("no PVC deletions recorded")
+			results = append(results, result)
+			continue
+		}
+		target, _ := time.ParseDuration(a.Value)
+		actual := durationMetric(summary, a.Metric)
+		result.Actual = actual.String()
+		result.Passed = compare(int64(actual), a.Op, int64(target))
+		results = append(results, result)
+	}
+	return results
+}
+
+func durationMetric(summary stats.Summary, metric string) time.Duration {
+	switch metric {
+	case "avg":
+		return summary.Mean
+	case "min":
+		return summary.Min
+	case "max":
+		return summary.Max
+	case "stddev":
+		return summary.StdDev
+	case "p50":
+		return summary.P50
+	case "p90":
+		return summary.P90
+	case "p99":
+		return summary.P99
+	case "p99.9":
+		return summary.P999
+	}
+	return 0
+}
+
+func compare(actual int64, op string, target int64) bool {
+	switch op {
+	case "<=":
+		return actual <= target
+	case ">=":
+		return actual >= target
+	case "<":
+		return actual < target
+	case ">":
+		return actual > target
+	case "==":
+		return actual == target
+	}
+	return false
+}
+
+func printAssertionResults(results []AssertionResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Println("\n=== SLO Assertions ===")
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("FAIL  %s (%v)\n", r.Assertion.Expr, r.Err)
+		case r.Passed:
+			fmt.Printf("PASS  %s (actual: %s)\n", r.Assertion.Expr, r.Actual)
+		default:
+			fmt.Printf("FAIL  %s (actual: %s)\n", r.Assertion.Expr, r.Actual)
+		}
+	}
+	fmt.Println("======================")
+}
+
+func assertionError(results []AssertionResult) error {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil || !r.Passed {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return &assertionFailedError{Failed: failed, Total: len(results)}
+}
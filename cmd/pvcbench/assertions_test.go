@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		expr    string
+		metric  string
+		op      string
+		value   string
+		wantErr bool
+	}{
+		{expr: "p99<=5s", metric: "p99", op: "<=", value: "5s"},
+		{expr: "max<30s", metric: "max", op: "<", value: "30s"},
+		{expr: "count=replicas", metric: "count", op: "==", value: "replicas"},
+		{expr: "count>=10", metric: "count", op: ">=", value: "10"},
+		{expr: " P50 <= 1s ", metric: "p50", op: "<=", value: "1s"},
+		{expr: "p99<=soon", wantErr: true},
+		{expr: "p95<=1s", wantErr: true},
+		{expr: "count=all", wantErr: true},
+		{expr: "p99", wantErr: true},
+		{expr: "<=5s", wantErr: true},
+		{expr: "p99<=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		a, err := parseAssertion(tt.expr)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("%q: expected error, got %+v", tt.expr, a)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.expr, err)
+		}
+		if a.Metric != tt.metric || a.Op != tt.op || a.Value != tt.value {
+			t.Fatalf("%q: got metric=%q op=%q value=%q", tt.expr, a.Metric, a.Op, a.Value)
+		}
+	}
+}
+
+func TestEvaluateAssertions(t *testing.T) {
+	latencies := []time.Duration{3 * time.Second, time.Second, 2 * time.Second}
+	assertions, err := parseAssertions([]string{"p99<=5s", "max<=2s", "count=replicas", "count=4", "min>=1s"})
+	if err != nil {
+		t.Fatalf("parseAssertions: %v", err)
+	}
+
+	results := evaluateAssertions(assertions, latencies, 3)
+	want := []bool{true, false, true, false, true}
+	for i, r := range results {
+		if r.Passed != want[i] {
+			t.Fatalf("%s: expected passed=%v, got %v (actual %s)", r.Assertion.Expr, want[i], r.Passed, r.Actual)
+		}
+	}
+	if latencies[0] != 3*time.Second {
+		t.Fatalf("expected input latencies to be left unsorted")
+	}
+
+	err = assertionError(results)
+	var assertErr *assertionFailedError
+	if !errors.As(err, &assertErr) {
+		t.Fatalf("expected assertionFailedError, got %v", err)
+	}
+	if assertErr.Failed != 2 || assertErr.Total != 5 {
+		t.Fatalf("expected 2 of 5 failed, got %d of %d", assertErr.Failed, assertErr.Total)
+	}
+}
+
+func TestEvaluateAssertionsWithoutSamples(t *testing.T) {
+	assertions, err := parseAssertions([]string{"p99<=5s"})
+	if err != nil {
+		t.Fatalf("parseAssertions: %v", err)
+	}
+	results := evaluateAssertions(assertions, nil, 3)
+	if results[0].Err == nil {
+		t.Fatalf("expected error when no samples were recorded")
+	}
+	if assertionError(results) == nil {
+		t.Fatalf("expected assertion failure")
+	}
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	assertions, err := parseAssertions([]string{"p99<=5s", "max<=1s"})
+	if err != nil {
+		t.Fatalf("parseAssertions: %v", err)
+	}
+	results := evaluateAssertions(assertions, []time.Duration{2 * time.Second}, 1)
+	inputs := SummaryInputs{Scenario: "burst", Replicas: 1}
+
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	if err := writeJUnitReport(path, buildJUnitReport(inputs, time.Second, nil, results)); err != nil {
+		t.Fatalf("writeJUnitReport: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(data, &report); err != nil {
+		t.Fatalf("unmarshal report: %v", err)
+	}
+	suite := report.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Errors != 0 {
+		t.Fatalf("unexpected suite counts: tests=%d failures=%d errors=%d", suite.Tests, suite.Failures, suite.Errors)
+	}
+
+	runErrReport := buildJUnitReport(inputs, 0, fmt.Errorf("boom"), nil)
+	if runErrReport.Suites[0].Errors != 1 || !strings.Contains(runErrReport.Suites[0].Cases[0].Error.Text, "boom") {
+		t.Fatalf("expected run error to be reported")
+	}
+}
@@ -2,14 +2,26 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
 	"time"
 
+	"pvc-protection-bench/pkg/history"
 	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/logging"
 	"pvc-protection-bench/pkg/metrics"
 	"pvc-protection-bench/pkg/scenarios"
 
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
@@ -19,6 +31,29 @@ var (
 	batchSize       int32
 	deleteInterval  time.Duration
 	pvcPollInterval time.Duration
+	assertExprs     []string
+	junitReport     string
+	runTags         map[string]string
+	noHistory       bool
+	keepNamespace   bool
+	autoCleanup     bool
+	runNamespace    string
+	reuse           bool
+	staticPVs       k8s.StaticPVOptions
+	shards          int
+	safetyMargin    float64
+	forceOversize   bool
+	runTimeout      time.Duration
+	phaseTimeouts   scenarios.Timeouts
+	cleanupTimeout  time.Duration
+	stuckThreshold  time.Duration
+	stuckPolicy     string
+	retryPolicy     k8s.RetryPolicy
+	lockNamespace   string
+	lockName        string
+	waitForLock     bool
+	noLock          bool
+	skipPreflight   bool
 )
 
 var benchmarkCmd = &cobra.Command{
@@ -28,8 +63,33 @@ var benchmarkCmd = &cobra.Command{
 		if err := validateBenchmarkInputs(scenario, replicas, pvcSize, batchSize, deleteInterval, pvcPollInterval); err != nil {
 			return err
 		}
+		if err := validateTimeouts(runTimeout, phaseTimeouts, cleanupTimeout); err != nil {
+			return err
+		}
+		if err := validateStuckOptions(stuckThreshold, stuckPolicy); err != nil {
+			return err
+		}
+		if err := validateRetryPolicy(retryPolicy); err != nil {
+			return err
+		}
+		if err := validateNamespaceOptions(runNamespace, reuse, keepNamespace, autoCleanup); err != nil {
+			return err
+		}
+		if err := validateStaticPVOptions(staticPVs); err != nil {
+			return err
+		}
+		if err := validateShards(shards, replicas); err != nil {
+			return err
+		}
+		if err := validateSafetyMargin(safetyMargin); err != nil {
+			return err
+		}
+		assertions, err := parseAssertions(assertExprs)
+		if err != nil {
+			return err
+		}
 
-		client, err := k8s.NewClient(clientQPS, clientBurst)
+		client, err := newClient()
 		if err != nil {
 			return err
 		}
@@ -40,63 +100,470 @@ var benchmarkCmd = &cobra.Command{
 			k8sVersion = serverVersion.GitVersion
 		}
 
-		namespace := fmt.Sprintf("pvcbench-%d", time.Now().Unix())
+		runID := k8s.NewRunID()
+		namespace := k8s.RunNamespace(runID)
+		if runNamespace != "" {
+			if _, err := client.CoreV1().Namespaces().Get(context.Background(), runNamespace, metav1.GetOptions{}); err != nil {
+				return fmt.Errorf("failed to get namespace %s: %v", runNamespace, err)
+			}
+			namespace = runNamespace
+		}
 		metrics.StartMetricsServer(metricsPort, namespace)
 
+		summaryInputs := SummaryInputs{
+			RunID:             runID,
+			Scenario:          scenario,
+			Replicas:          replicas,
+			PVCSize:           pvcSize,
+			DeleteBatchSize:   batchSize,
+			DeleteInterval:    deleteInterval,
+			PVCPollInterval:   pvcPollInterval,
+			Shards:            shards,
+			KubernetesVersion: k8sVersion,
+		}
 		config := k8s.StatefulSetConfig{
 			Name:      "pvcbench-sts",
 			Namespace: namespace,
 			Replicas:  replicas,
 			PVCSize:   pvcSize,
+			Run:       runMetadata(summaryInputs),
+			StaticPVs: staticPVConfig(),
+			Shards:    shards,
 		}
 
-		ctx := context.Background()
+		signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := withRunTimeout(signalCtx, runTimeout)
+		defer cancel()
 
-		var totalDuration time.Duration
-		var latencies []time.Duration
+		if err := checkResourceBudget(ctx, client, config); err != nil {
+			return err
+		}
+
+		lock, err := acquireRunLock(ctx, client)
+		if err != nil {
+			return err
+		}
+		defer releaseRunLock(lock)
+		runCtx, cancelRun := watchRunLock(ctx, lock)
+		defer cancelRun()
+
+		result, err := runScenario(runCtx, client, scenario, config)
+		// Only a signal aborts the run; running out of --timeout is a failure.
+		aborted := signalCtx.Err() != nil
+		timedOut := !aborted && errors.Is(ctx.Err(), context.DeadlineExceeded)
+		// Restore default signal handling so a second Ctrl-C exits immediately.
+		stop()
+		if lockErr := runLockError(lock); lockErr != nil {
+			err = lockErr
+		} else if timedOut {
+			err = runTimeoutError(runTimeout, err)
+		}
 
-		switch scenario {
-		case "burst":
-			totalDuration, latencies, err = scenarios.RunBurstDelete(ctx, client, config, pvcPollInterval)
-		case "staggered":
-			opts := scenarios.StaggeredDeleteOptions{
-				BatchSize: batchSize,
-				Interval:  deleteInterval,
+		summaryInputs.LockHolder = lockHolder(lock)
+		summaryInputs.Aborted = aborted
+
+		var results []AssertionResult
+		if aborted {
+			printSummary(result.TotalDuration, result.Latencies(), summaryInputs)
+			printBatchSummary(result.Batches())
+			printExcludedPVCs(result.Excluded)
+			err = abortedError(err)
+		} else if err == nil {
+			printSummary(result.TotalDuration, result.Latencies(), summaryInputs)
+			printBatchSummary(result.Batches())
+			printExcludedPVCs(result.Excluded)
+			printPVReport(result.PVs)
+			if !noHistory {
+				record := newHistoryRecord(summaryInputs, result.TotalDuration, result.Latencies(), result.Batches(), runTags)
+				if err := history.NewStore(historyDir).Append(record); err != nil {
+					logging.GetLogger().Error("failed to record run in history", logging.ErrorField(err))
+				}
 			}
-			totalDuration, latencies, err = scenarios.RunStaggeredDelete(ctx, client, config, opts, pvcPollInterval)
-		default:
-			return fmt.Errorf("unknown scenario: %s", scenario)
+			results = evaluateAssertions(assertions, result.Latencies(), replicas)
+			printAssertionResults(results)
 		}
 
-		if err == nil {
-			summaryInputs := SummaryInputs{
-				Scenario:          scenario,
-				Replicas:          replicas,
-				PVCSize:           pvcSize,
-				DeleteBatchSize:   batchSize,
-				DeleteInterval:    deleteInterval,
-				PVCPollInterval:   pvcPollInterval,
-				KubernetesVersion: k8sVersion,
+		if shouldTeardown(aborted, keepNamespace, autoCleanup) {
+			if cleanupErr := teardownRun(client, config, runNamespace == ""); cleanupErr != nil {
+				logging.GetLogger().Error("failed to clean up after run", logging.StringField("namespace", namespace), logging.ErrorField(cleanupErr))
 			}
-			printSummary(totalDuration, latencies, summaryInputs)
+		} else {
+			logging.GetLogger().Info("keeping run resources", logging.StringField("namespace", namespace))
 		}
 
-		return err
+		if junitReport != "" {
+			if writeErr := writeJUnitReport(junitReport, buildJUnitReport(summaryInputs, result.TotalDuration, err, results)); writeErr != nil && err == nil {
+				err = writeErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := assertionError(results); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
 	},
 }
 
 func init() {
-	benchmarkCmd.Flags().StringVar(&scenario, "scenario", "burst", "Scenario to run: burst (all-at-once), staggered (batched)")
+	addScenarioFlags(benchmarkCmd)
 	benchmarkCmd.Flags().Int32Var(&replicas, "replicas", 100, "Number of replicas")
-	benchmarkCmd.Flags().StringVar(&pvcSize, "pvc-size", "100Mi", "PVC size")
 
-	benchmarkCmd.Flags().Int32Var(&batchSize, "delete-batch-size", 10, "Batch size for staggered scenario")
-	benchmarkCmd.Flags().DurationVar(&deleteInterval, "delete-interval", 5*time.Second, "Interval between batches for staggered scenario")
-	benchmarkCmd.Flags().DurationVar(&pvcPollInterval, "pvc-poll-interval", 100*time.Millisecond, "Interval for PVC GET polling")
+	benchmarkCmd.Flags().StringArrayVar(&assertExprs, "assert", nil, "SLO assertion evaluated after the run, e.g. p99<=5s, max<=30s, count=replicas (repeatable)")
+	benchmarkCmd.Flags().StringVar(&junitReport, "junit-report", "", "Write assertion results as JUnit XML to this path")
+	benchmarkCmd.Flags().StringToStringVar(&runTags, "tag", nil, "Tag recorded with the run in the history store (key=value, repeatable)")
+	benchmarkCmd.Flags().BoolVar(&noHistory, "no-history", false, "Do not record the run in the history store")
+	benchmarkCmd.Flags().BoolVar(&keepNamespace, "keep-namespace", false, "Never clean up after the run, even when it is aborted with SIGINT/SIGTERM")
+	benchmarkCmd.Flags().BoolVar(&autoCleanup, "auto-cleanup", false, "Clean up after every run, not only aborted ones")
+	benchmarkCmd.Flags().StringVar(&runNamespace, "namespace", "", "Run in this existing namespace instead of creating one; cleanup then deletes only the StatefulSet")
+	benchmarkCmd.Flags().BoolVar(&reuse, "reuse", false, "Keep an existing StatefulSet in --namespace that matches the run instead of recreating it, scaling it back up if a completed run left it at 0")
 
 	rootCmd.AddCommand(benchmarkCmd)
 }
 
+func addScenarioFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&scenario, "scenario", "burst", "Scenario to run: burst (all-at-once), staggered (batched)")
+	cmd.Flags().StringVar(&pvcSize, "pvc-size", "100Mi", "PVC size")
+
+	cmd.Flags().Int32Var(&batchSize, "delete-batch-size", 10, "Batch size for staggered scenario")
+	cmd.Flags().DurationVar(&deleteInterval, "delete-interval", 5*time.Second, "Interval between batches for staggered scenario")
+	cmd.Flags().DurationVar(&pvcPollInterval, "pvc-poll-interval", 100*time.Millisecond, "Interval for PVC GET polling")
+
+	cmd.Flags().IntVar(&shards, "shards", 1, "Split the replicas across this many StatefulSets (pvcbench-sts-0..N-1)")
+	cmd.Flags().StringVar(&staticPVs.Type, "static-pvs", "", "Pre-create PVs of this type (hostPath or local) and PVCs bound to them instead of using dynamic provisioning")
+	cmd.Flags().StringVar(&staticPVs.Path, "static-pv-path", "/tmp/pvcbench", "Node directory every static PV points at (must exist for local PVs)")
+	cmd.Flags().StringSliceVar(&staticPVs.Nodes, "static-pv-nodes", nil, "Nodes local PVs are spread across, round-robin by ordinal")
+	cmd.Flags().IntVar(&staticPVs.Workers, "static-pv-workers", 16, "Number of static PVs and PVCs created in parallel")
+
+	cmd.Flags().Float64Var(&safetyMargin, "safety-margin", 0.2, "Headroom the cluster must have beyond the estimated pods, PVCs and storage of a run, as a fraction of the estimate")
+	cmd.Flags().BoolVar(&forceOversize, "force-oversize", false, "Start runs that do not fit node capacity, storage capacity or quota with --safety-margin to spare")
+
+	cmd.Flags().DurationVar(&runTimeout, "timeout", 0, "Overall time limit for the command (0 = no limit)")
+	cmd.Flags().DurationVar(&phaseTimeouts.Preflight, "preflight-timeout", 5*time.Minute, "Time limit for leftovers of earlier runs to clear before measuring (0 = no limit)")
+	cmd.Flags().BoolVar(&skipPreflight, "skip-preflight", false, "Start measuring without waiting for the cluster to become quiet")
+	cmd.Flags().DurationVar(&phaseTimeouts.Setup, "setup-timeout", 5*time.Minute, "Time limit for creating the namespace and StatefulSet (0 = no limit)")
+	cmd.Flags().DurationVar(&phaseTimeouts.Ready, "ready-timeout", 10*time.Minute, "Time limit for the pods of all shards to become ready (0 = no limit)")
+	cmd.Flags().DurationVar(&phaseTimeouts.ScaleDown, "scale-down-timeout", 5*time.Minute, "Time limit for each scale-down request, excluding the intervals between batches (0 = no limit)")
+	cmd.Flags().DurationVar(&phaseTimeouts.Drain, "drain-timeout", 30*time.Minute, "Time limit for all PVCs to be deleted after scale-down (0 = no limit)")
+	cmd.Flags().DurationVar(&phaseTimeouts.PVDeletion, "pv-timeout", 2*time.Minute, "Time limit for tracking the deletion of PVs after their PVCs are gone (0 = no limit)")
+	cmd.Flags().DurationVar(&cleanupTimeout, "cleanup-timeout", 10*time.Minute, "Time limit for a namespace to be deleted (0 = no limit)")
+
+	cmd.Flags().DurationVar(&stuckThreshold, "stuck-threshold", 5*time.Minute, "Time after which a terminating PVC is diagnosed as stuck (0 = never)")
+	cmd.Flags().StringVar(&stuckPolicy, "stuck-policy", k8s.StuckPolicyFail, "What to do with stuck PVCs: fail (abort the run), exclude (drop them from the results)")
+
+	cmd.Flags().StringVar(&lockNamespace, "lock-namespace", k8s.DefaultLockNamespace, "Namespace of the Lease that keeps concurrent runs from interfering")
+	cmd.Flags().StringVar(&lockName, "lock-name", k8s.DefaultLockName, "Name of the run lock Lease")
+	cmd.Flags().BoolVar(&waitForLock, "wait-for-lock", false, "Wait for another run to release the lock instead of failing")
+	cmd.Flags().BoolVar(&noLock, "no-lock", false, "Run without taking the run lock")
+
+	cmd.Flags().IntVar(&retryPolicy.ErrorBudget, "error-budget", 50, "Number of transient API errors (throttling, timeouts, 5xx, connection resets) retried during PVC polling before the run fails")
+	cmd.Flags().DurationVar(&retryPolicy.InitialBackoff, "retry-backoff", 200*time.Millisecond, "Initial backoff before retrying a transient API error (doubled with jitter on each retry)")
+	cmd.Flags().DurationVar(&retryPolicy.MaxBackoff, "retry-max-backoff", 10*time.Second, "Upper bound for the retry backoff")
+}
+
+func withRunTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func validateTimeouts(run time.Duration, phases scenarios.Timeouts, cleanup time.Duration) error {
+	for name, d := range map[string]time.Duration{
+		"timeout":            run,
+		"preflight-timeout":  phases.Preflight,
+		"setup-timeout":      phases.Setup,
+		"ready-timeout":      phases.Ready,
+		"scale-down-timeout": phases.ScaleDown,
+		"drain-timeout":      phases.Drain,
+		"pv-timeout":         phases.PVDeletion,
+		"cleanup-timeout":    cleanup,
+	} {
+		if d < 0 {
+			return fmt.Errorf("%s must be >= 0 (got %s)", name, d)
+		}
+	}
+	return nil
+}
+
+func validateStuckOptions(threshold time.Duration, policy string) error {
+	if threshold < 0 {
+		return fmt.Errorf("stuck-threshold must be >= 0 (got %s)", threshold)
+	}
+	if policy != k8s.StuckPolicyFail && policy != k8s.StuckPolicyExclude {
+		return fmt.Errorf("invalid stuck-policy: %s (must be %s or %s)", policy, k8s.StuckPolicyFail, k8s.StuckPolicyExclude)
+	}
+	return nil
+}
+
+func validateRetryPolicy(policy k8s.RetryPolicy) error {
+	if policy.ErrorBudget < 0 {
+		return fmt.Errorf("error-budget must be >= 0 (got %d)", policy.ErrorBudget)
+	}
+	if policy.InitialBackoff < 0 {
+		return fmt.Errorf("retry-backoff must be >= 0 (got %s)", policy.InitialBackoff)
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		return fmt.Errorf("retry-max-backoff must be >= retry-backoff (got %s < %s)", policy.MaxBackoff, policy.InitialBackoff)
+	}
+	return nil
+}
+
+// runMetadata labels the resources of a run so that cleanup and people
+// inspecting the cluster can tell which run created them.
+func runMetadata(inputs SummaryInputs) k8s.RunMetadata {
+	return k8s.RunMetadata{
+		RunID:       inputs.RunID,
+		Scenario:    inputs.Scenario,
+		Params:      summaryParams(inputs),
+		ToolVersion: toolVersion(),
+		Operator:    k8s.Operator(),
+	}
+}
+
+// acquireRunLock takes the cluster-wide run lock unless --no-lock is set, in
+// which case it returns a nil lock.
+func acquireRunLock(ctx context.Context, client kubernetes.Interface) (*k8s.RunLock, error) {
+	if noLock {
+		logging.GetLogger().Warn("running without the run lock; concurrent runs may skew results")
+		return nil, nil
+	}
+	return k8s.AcquireLock(ctx, client, k8s.LockOptions{
+		Namespace:     lockNamespace,
+		Name:          lockName,
+		Identity:      k8s.LockIdentity(),
+		LeaseDuration: 30 * time.Second,
+		Wait:          waitForLock,
+	})
+}
+
+func releaseRunLock(lock *k8s.RunLock) {
+	if lock == nil {
+		return
+	}
+	if err := lock.Release(); err != nil {
+		logging.GetLogger().Error("failed to release run lock", logging.ErrorField(err))
+	}
+}
+
+// lockHolder is empty when the run did not hold the lock throughout.
+func lockHolder(lock *k8s.RunLock) string {
+	if lock == nil || lock.Err() != nil {
+		return ""
+	}
+	return lock.Holder()
+}
+
+// watchRunLock returns a context that is cancelled when the run lock is lost,
+// so that a run sharing the cluster with another one stops instead of
+// recording skewed results.
+func watchRunLock(ctx context.Context, lock *k8s.RunLock) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if lock == nil {
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-lock.Lost():
+			logging.GetLogger().Error("run lock lost, stopping the run", logging.ErrorField(lock.Err()))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// runLockError reports a lost run lock, which invalidates the results.
+func runLockError(lock *k8s.RunLock) error {
+	if lock == nil || lock.Err() == nil {
+		return nil
+	}
+	return fmt.Errorf("run invalidated: %v", lock.Err())
+}
+
+func validateShards(shards int, replicas int32) error {
+	if shards <= 0 || int32(shards) > replicas {
+		return fmt.Errorf("shards must be > 0 and <= replicas (got %d, replicas=%d)", shards, replicas)
+	}
+	return nil
+}
+
+func validateSafetyMargin(margin float64) error {
+	if margin < 0 {
+		return fmt.Errorf("safety-margin must be >= 0 (got %g)", margin)
+	}
+	return nil
+}
+
+// checkResourceBudget refuses to start a run that does not fit the cluster
+// with --safety-margin to spare. With --force-oversize it only warns.
+func checkResourceBudget(ctx context.Context, client kubernetes.Interface, config k8s.StatefulSetConfig) error {
+	limits, err := k8s.BudgetLimits(ctx, client, config)
+	if err != nil {
+		err = fmt.Errorf("failed to check resource budget: %v", err)
+	} else {
+		err = oversizeError(limits, safetyMargin)
+	}
+	if err != nil && forceOversize {
+		logging.GetLogger().Warn("starting run despite --force-oversize", logging.ErrorField(err))
+		return nil
+	}
+	return err
+}
+
+// abortedError reports an interrupted run. A scenario that returned cleanly
+// before noticing the interrupt leaves err nil.
+func abortedError(err error) error {
+	if err == nil {
+		return errors.New("benchmark aborted")
+	}
+	return fmt.Errorf("benchmark aborted: %w", err)
+}
+
+// runTimeoutError reports a run stopped by the --timeout deadline.
+func runTimeoutError(timeout time.Duration, err error) error {
+	if err == nil {
+		return fmt.Errorf("run timed out after %s", timeout)
+	}
+	return fmt.Errorf("run timed out after %s: %w", timeout, err)
+}
+
+func oversizeError(limits []k8s.BudgetLimit, margin float64) error {
+	var exceeded []string
+	for _, limit := range limits {
+		if limit.Exceeded(margin) {
+			exceeded = append(exceeded, fmt.Sprintf("need %s %s, %s leaves %s", limit.Needed.String(), limit.Resource, limit.Source, limit.Available.String()))
+		}
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+	return fmt.Errorf("run does not fit the cluster with a %.0f%% safety margin: %s; pass --force-oversize to start it anyway", margin*100, strings.Join(exceeded, "; "))
+}
+
+func validateStaticPVOptions(opts k8s.StaticPVOptions) error {
+	switch opts.Type {
+	case "":
+		return nil
+	case k8s.StaticPVHostPath, k8s.StaticPVLocal:
+	default:
+		return fmt.Errorf("invalid static-pvs: %s (must be %s or %s)", opts.Type, k8s.StaticPVHostPath, k8s.StaticPVLocal)
+	}
+	if !path.IsAbs(opts.Path) {
+		return fmt.Errorf("static-pv-path must be an absolute path (got %q)", opts.Path)
+	}
+	if opts.Type == k8s.StaticPVLocal && len(opts.Nodes) == 0 {
+		return fmt.Errorf("--static-pvs=%s requires --static-pv-nodes", k8s.StaticPVLocal)
+	}
+	if opts.Workers <= 0 {
+		return fmt.Errorf("static-pv-workers must be > 0 (got %d)", opts.Workers)
+	}
+	return nil
+}
+
+// staticPVConfig returns the static PV options for a StatefulSetConfig, nil
+// when PVs are provisioned dynamically.
+func staticPVConfig() *k8s.StaticPVOptions {
+	if staticPVs.Type == "" {
+		return nil
+	}
+	opts := staticPVs
+	return &opts
+}
+
+func validateNamespaceOptions(namespace string, reuse, keep, autoCleanup bool) error {
+	if keep && autoCleanup {
+		return fmt.Errorf("--keep-namespace and --auto-cleanup are mutually exclusive")
+	}
+	if namespace != "" {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, "; "))
+		}
+	}
+	if reuse && namespace == "" {
+		return fmt.Errorf("--reuse requires --namespace, since every run otherwise gets a new namespace")
+	}
+	return nil
+}
+
+// shouldTeardown decides whether a run cleans up after itself. By default
+// only aborted runs do, so that completed runs can be inspected.
+func shouldTeardown(aborted, keep, autoCleanup bool) bool {
+	if keep {
+		return false
+	}
+	return aborted || autoCleanup
+}
+
+// teardownRun deletes the run namespace when the run created it, and only the
+// StatefulSets when it ran in a namespace given with --namespace. Static PVs
+// are cluster-scoped and outlive both, so they are deleted last.
+func teardownRun(client kubernetes.Interface, config k8s.StatefulSetConfig, ownsNamespace bool) error {
+	ctx := context.Background()
+	if ownsNamespace {
+		if err := deleteRunNamespace(client, config.Namespace); err != nil {
+			return err
+		}
+	}
+	for _, shard := range config.ShardConfigs() {
+		if !ownsNamespace {
+			logging.GetLogger().Info("deleting statefulset", logging.StringField("namespace", shard.Namespace), logging.StringField("name", shard.Name))
+			if err := k8s.DeleteStatefulSet(ctx, client, shard.Namespace, shard.Name); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+		if shard.StaticPVs != nil {
+			logging.GetLogger().Info("deleting static pvs", logging.StringField("name", shard.Name))
+			if err := k8s.DeleteStaticPVs(ctx, client, shard); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deleteRunNamespace(client kubernetes.Interface, namespace string) error {
+	ctx := context.Background()
+
+	logging.GetLogger().Info("deleting namespace", logging.StringField("name", namespace))
+	if err := k8s.DeleteNamespace(ctx, client, namespace); err != nil {
+		return err
+	}
+	return k8s.WaitForNamespaceDeleted(ctx, client, namespace, cleanupTimeout)
+}
+
+func runScenario(ctx context.Context, client kubernetes.Interface, scenario string, config k8s.StatefulSetConfig) (scenarios.Result, error) {
+	runOpts := scenarios.RunOptions{
+		PollInterval:   pvcPollInterval,
+		Timeouts:       phaseTimeouts,
+		StuckThreshold: stuckThreshold,
+		StuckPolicy:    stuckPolicy,
+		Retry:          retryPolicy,
+		Reuse:          reuse,
+	}
+	if skipPreflight {
+		logging.GetLogger().Warn("skipping preflight quiescence check; leftovers of earlier runs may skew results")
+	} else if err := k8s.WaitForQuiescence(ctx, client, phaseTimeouts.Preflight); err != nil {
+		return scenarios.Result{}, err
+	}
+
+	switch scenario {
+	case "burst":
+		return scenarios.RunBurstDelete(ctx, client, config, runOpts)
+	case "staggered":
+		opts := scenarios.StaggeredDeleteOptions{
+			BatchSize: batchSize,
+			Interval:  deleteInterval,
+		}
+		return scenarios.RunStaggeredDelete(ctx, client, config, opts, runOpts)
+	}
+	return scenarios.Result{}, fmt.Errorf("unknown scenario: %s", scenario)
+}
+
 func validateBenchmarkInputs(scenario string, replicas int32, pvcSize string, batchSize int32, deleteInterval, pvcPollInterval time.Duration) error {
 	if scenario != "burst" && scenario != "staggered" {
 		return fmt.Errorf("unknown scenario: %s", scenario)
@@ -1,8 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/scenarios"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestValidateBenchmarkInputs(t *testing.T) {
@@ -108,3 +120,232 @@ func TestValidateBenchmarkInputs(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateTimeouts(t *testing.T) {
+	phases := scenarios.Timeouts{Setup: time.Minute, Ready: time.Minute, ScaleDown: time.Minute, Drain: 0}
+	if err := validateTimeouts(0, phases, time.Minute); err != nil {
+		t.Fatalf("expected zero and positive timeouts to be valid: %v", err)
+	}
+	phases.Drain = -time.Second
+	if err := validateTimeouts(0, phases, time.Minute); err == nil {
+		t.Fatalf("expected error for negative drain timeout")
+	}
+	if err := validateTimeouts(-time.Second, scenarios.Timeouts{}, 0); err == nil {
+		t.Fatalf("expected error for negative run timeout")
+	}
+}
+
+func TestValidateStuckOptions(t *testing.T) {
+	if err := validateStuckOptions(5*time.Minute, "fail"); err != nil {
+		t.Fatalf("expected fail policy to be valid: %v", err)
+	}
+	if err := validateStuckOptions(0, "exclude"); err != nil {
+		t.Fatalf("expected exclude policy to be valid: %v", err)
+	}
+	if err := validateStuckOptions(time.Minute, "ignore"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+	if err := validateStuckOptions(-time.Second, "fail"); err == nil {
+		t.Fatalf("expected error for negative threshold")
+	}
+}
+
+func TestValidateRetryPolicy(t *testing.T) {
+	if err := validateRetryPolicy(k8s.RetryPolicy{ErrorBudget: 50, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}); err != nil {
+		t.Fatalf("expected policy to be valid: %v", err)
+	}
+	if err := validateRetryPolicy(k8s.RetryPolicy{}); err != nil {
+		t.Fatalf("expected zero policy to be valid: %v", err)
+	}
+	if err := validateRetryPolicy(k8s.RetryPolicy{ErrorBudget: -1}); err == nil {
+		t.Fatalf("expected error for negative budget")
+	}
+	if err := validateRetryPolicy(k8s.RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Millisecond}); err == nil {
+		t.Fatalf("expected error for max backoff below initial backoff")
+	}
+}
+
+func TestValidateNamespaceOptions(t *testing.T) {
+	if err := validateNamespaceOptions("", false, false, false); err != nil {
+		t.Fatalf("expected defaults to be valid: %v", err)
+	}
+	if err := validateNamespaceOptions("pvcbench-dev", true, true, false); err != nil {
+		t.Fatalf("expected reuse in an existing namespace to be valid: %v", err)
+	}
+	if err := validateNamespaceOptions("", false, true, true); err == nil {
+		t.Fatalf("expected error for --keep-namespace with --auto-cleanup")
+	}
+	if err := validateNamespaceOptions("", true, false, false); err == nil {
+		t.Fatalf("expected error for --reuse without --namespace")
+	}
+	if err := validateNamespaceOptions("Bad_Name", false, false, false); err == nil {
+		t.Fatalf("expected error for invalid namespace name")
+	}
+}
+
+func TestShouldTeardown(t *testing.T) {
+	for _, tc := range []struct {
+		aborted, keep, autoCleanup, want bool
+	}{
+		{aborted: false, want: false},
+		{aborted: true, want: true},
+		{aborted: true, keep: true, want: false},
+		{aborted: false, autoCleanup: true, want: true},
+	} {
+		if got := shouldTeardown(tc.aborted, tc.keep, tc.autoCleanup); got != tc.want {
+			t.Fatalf("shouldTeardown(aborted=%t, keep=%t, autoCleanup=%t) = %t, want %t", tc.aborted, tc.keep, tc.autoCleanup, got, tc.want)
+		}
+	}
+}
+
+func TestValidateStaticPVOptions(t *testing.T) {
+	if err := validateStaticPVOptions(k8s.StaticPVOptions{}); err != nil {
+		t.Fatalf("expected dynamic provisioning to be valid: %v", err)
+	}
+	if err := validateStaticPVOptions(k8s.StaticPVOptions{Type: k8s.StaticPVHostPath, Path: "/tmp/pvcbench", Workers: 16}); err != nil {
+		t.Fatalf("expected hostPath options to be valid: %v", err)
+	}
+	if err := validateStaticPVOptions(k8s.StaticPVOptions{Type: "nfs", Path: "/tmp/pvcbench", Workers: 16}); err == nil {
+		t.Fatalf("expected error for unknown type")
+	}
+	if err := validateStaticPVOptions(k8s.StaticPVOptions{Type: k8s.StaticPVHostPath, Path: "tmp", Workers: 16}); err == nil {
+		t.Fatalf("expected error for relative path")
+	}
+	if err := validateStaticPVOptions(k8s.StaticPVOptions{Type: k8s.StaticPVLocal, Path: "/mnt/pvcbench", Workers: 16}); err == nil {
+		t.Fatalf("expected error for local PVs without nodes")
+	}
+	if err := validateStaticPVOptions(k8s.StaticPVOptions{Type: k8s.StaticPVHostPath, Path: "/tmp/pvcbench"}); err == nil {
+		t.Fatalf("expected error for zero workers")
+	}
+}
+
+func TestValidateShards(t *testing.T) {
+	if err := validateShards(1, 10); err != nil {
+		t.Fatalf("expected a single shard to be valid: %v", err)
+	}
+	if err := validateShards(10, 10); err != nil {
+		t.Fatalf("expected one replica per shard to be valid: %v", err)
+	}
+	if err := validateShards(0, 10); err == nil {
+		t.Fatalf("expected error for zero shards")
+	}
+	if err := validateShards(11, 10); err == nil {
+		t.Fatalf("expected error for more shards than replicas")
+	}
+}
+
+func TestValidateSafetyMargin(t *testing.T) {
+	if err := validateSafetyMargin(0); err != nil {
+		t.Fatalf("expected zero margin to be valid: %v", err)
+	}
+	if err := validateSafetyMargin(-0.1); err == nil {
+		t.Fatalf("expected error for negative margin")
+	}
+}
+
+func TestAbortedError(t *testing.T) {
+	if err := abortedError(nil); err.Error() != "benchmark aborted" {
+		t.Fatalf("unexpected error for a clean abort: %q", err)
+	}
+	err := abortedError(context.Canceled)
+	if !errors.Is(err, context.Canceled) || err.Error() != "benchmark aborted: context canceled" {
+		t.Fatalf("expected the scenario error to be wrapped, got %q", err)
+	}
+}
+
+func TestRunTimeoutError(t *testing.T) {
+	if err := runTimeoutError(time.Hour, nil); err.Error() != "run timed out after 1h0m0s" {
+		t.Fatalf("unexpected error without a scenario error: %q", err)
+	}
+	err := runTimeoutError(time.Hour, context.DeadlineExceeded)
+	if !errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "aborted") {
+		t.Fatalf("expected a wrapped timeout that is not reported as an abort, got %q", err)
+	}
+}
+
+func TestOversizeError(t *testing.T) {
+	limits := []k8s.BudgetLimit{
+		{Resource: corev1.ResourcePods, Source: "node allocatable", Needed: resource.MustParse("100"), Available: resource.MustParse("110")},
+		{Resource: corev1.ResourceRequestsStorage, Source: "ResourceQuota small", Needed: resource.MustParse("10Gi"), Available: resource.MustParse("100Gi")},
+	}
+	if err := oversizeError(limits, 0); err != nil {
+		t.Fatalf("expected run to fit without margin: %v", err)
+	}
+	err := oversizeError(limits, 0.2)
+	if err == nil || !strings.Contains(err.Error(), "need 100 pods, node allocatable leaves 110") || !strings.Contains(err.Error(), "--force-oversize") {
+		t.Fatalf("expected pods to exceed a 20%% margin, got %v", err)
+	}
+	if strings.Contains(err.Error(), "ResourceQuota") {
+		t.Fatalf("expected storage quota to fit, got %v", err)
+	}
+}
+
+func TestTeardownRunDeletesStaticPVs(t *testing.T) {
+	config := k8s.StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: "pvcbench-dev",
+		Replicas:  2,
+		StaticPVs: &k8s.StaticPVOptions{Type: k8s.StaticPVHostPath, Workers: 1},
+	}
+	client := fake.NewSimpleClientset(
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: config.Name, Namespace: config.Namespace}},
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: k8s.StaticPVName(config.Namespace, config.Name, 0)}},
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: k8s.StaticPVName(config.Namespace, config.Name, 1)}},
+	)
+
+	if err := teardownRun(client, config, false); err != nil {
+		t.Fatalf("teardownRun error: %v", err)
+	}
+	pvs, err := client.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list pvs: %v", err)
+	}
+	if len(pvs.Items) != 0 {
+		t.Fatalf("expected static pvs to be deleted, got %v", pvs.Items)
+	}
+	if _, err := client.AppsV1().StatefulSets(config.Namespace).Get(context.Background(), config.Name, metav1.GetOptions{}); err == nil {
+		t.Fatalf("expected the statefulset to be deleted")
+	}
+}
+
+func TestWatchRunLockStopsRunOnTakeover(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+	lock, err := k8s.AcquireLock(ctx, client, k8s.LockOptions{
+		Namespace:     "default",
+		Name:          k8s.DefaultLockName,
+		Identity:      "alice",
+		LeaseDuration: 30 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("AcquireLock error: %v", err)
+	}
+	defer releaseRunLock(lock)
+	runCtx, cancel := watchRunLock(ctx, lock)
+	defer cancel()
+
+	lease, err := client.CoordinationV1().Leases("default").Get(ctx, k8s.DefaultLockName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	bob := "bob"
+	lease.Spec.HolderIdentity = &bob
+	if _, err := client.CoordinationV1().Leases("default").Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("take over lease: %v", err)
+	}
+
+	select {
+	case <-runCtx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the run context to be cancelled when the lock is lost")
+	}
+	if err := runLockError(lock); err == nil || !strings.Contains(err.Error(), "run invalidated") {
+		t.Fatalf("expected the run to be invalidated, got %v", err)
+	}
+	if holder := lockHolder(lock); holder != "" {
+		t.Fatalf("expected no lock holder to be recorded, got %q", holder)
+	}
+	if runLockError(nil) != nil || lockHolder(nil) != "" {
+		t.Fatalf("expected a run without a lock to be valid")
+	}
+}
@@ -2,18 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"pvc-protection-bench/pkg/k8s"
 	"pvc-protection-bench/pkg/logging"
 
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
 var (
-	forceDelete bool
+	forceDelete        bool
+	cleanupOlderThan   time.Duration
+	cleanupRunID       string
+	cleanupSelector    string
+	cleanupDryRun      bool
+	cleanupConcurrency int
+	cleanupDeletePVs   bool
+	cleanupPVs         bool
 )
 
 var cleanupCmd = &cobra.Command{
@@ -23,49 +39,79 @@ var cleanupCmd = &cobra.Command{
 		if err := validateCleanupArgs(args); err != nil {
 			return err
 		}
+		if err := validateCleanupFlags(cleanupOlderThan, cleanupSelector, cleanupConcurrency); err != nil {
+			return err
+		}
+		if cleanupDeletePVs && !forceDelete {
+			return fmt.Errorf("--delete-pvs requires --force")
+		}
+		if forceDelete && cleanupTimeout <= 0 {
+			return fmt.Errorf("--force requires a --cleanup-timeout to wait for normal deletion first")
+		}
 
-		client, err := k8s.NewClient(clientQPS, clientBurst)
+		client, dyn, err := newClients()
 		if err != nil {
 			return err
 		}
 
 		ctx := context.Background()
-		logger := logging.GetLogger()
-
-		namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		namespaces, err := listCleanupNamespaces(ctx, client, cleanupRunID, cleanupSelector)
 		if err != nil {
 			return err
 		}
+		names := selectCleanupNamespaces(namespaces, cleanupOlderThan, time.Now())
+		if len(names) == 0 {
+			fmt.Println("No matching namespaces.")
+			if !cleanupPVs {
+				return nil
+			}
+		}
 
-		for _, ns := range namespaces.Items {
-			shouldDelete := strings.HasPrefix(ns.Name, "pvcbench-")
-
-			if shouldDelete {
-				logger.Info("deleting namespace", logging.StringField("name", ns.Name))
-				if err := k8s.DeleteNamespace(ctx, client, ns.Name); err != nil {
-					logger.Error("failed to delete namespace", logging.StringField("name", ns.Name), logging.ErrorField(err))
+		if cleanupDryRun {
+			if len(names) > 0 {
+				if err := printCleanupPlan(ctx, client, names); err != nil {
 					return err
 				}
-				if forceDelete {
-					if err := k8s.ForceDeleteNamespace(ctx, client, ns.Name); err != nil {
-						logger.Error("force delete namespace failed", logging.StringField("name", ns.Name), logging.ErrorField(err))
-						return err
-					}
-				} else {
-					if err := k8s.WaitForNamespaceDeleted(ctx, client, ns.Name); err != nil {
-						logger.Error("waiting for namespace deletion failed", logging.StringField("name", ns.Name), logging.ErrorField(err))
-						return err
-					}
+			}
+			if cleanupPVs {
+				pvs, err := cleanupPVCandidates(ctx, client, names, true)
+				if err != nil {
+					return err
 				}
+				printPVCleanupPlan(pvs)
 			}
+			return nil
 		}
 
+		var errs []error
+		if len(names) > 0 {
+			if err := deleteNamespaces(ctx, client, dyn, names, cleanupConcurrency); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if cleanupPVs {
+			if err := deleteCleanupPVs(ctx, client, names); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			cmd.SilenceUsage = true
+			return errors.Join(errs...)
+		}
 		return nil
 	},
 }
 
 func init() {
-	cleanupCmd.Flags().BoolVar(&forceDelete, "force", false, "Force namespace deletion by removing finalizers")
+	cleanupCmd.Flags().BoolVar(&forceDelete, "force", false, "Force namespace deletion by removing finalizers from objects still terminating after --cleanup-timeout")
+	cleanupCmd.Flags().DurationVar(&cleanupTimeout, "cleanup-timeout", 10*time.Minute, "Time limit for each namespace to be deleted (0 = no limit)")
+	cleanupCmd.Flags().DurationVar(&cleanupOlderThan, "older-than", 0, "Only delete namespaces created at least this long ago")
+	cleanupCmd.Flags().StringVar(&cleanupRunID, "run-id", "", "Only delete the namespace of this run (matched on the "+k8s.LabelRunID+" label)")
+	cleanupCmd.Flags().StringVar(&cleanupSelector, "selector", "", "Only delete namespaces matching this label selector")
+	cleanupCmd.Flags().BoolVar(&cleanupDryRun, "dry-run", false, "Print the namespaces that would be deleted with their resource counts")
+	cleanupCmd.Flags().IntVar(&cleanupConcurrency, "concurrency", 1, "Number of namespaces deleted in parallel")
+	cleanupCmd.Flags().BoolVar(&cleanupPVs, "pvs", false, "Also delete leftover PersistentVolumes whose claimRef namespace is a deleted benchmark namespace")
+	cleanupCmd.Flags().BoolVar(&cleanupDeletePVs, "delete-pvs", false, "With --force, also delete PersistentVolumes whose claimRef points into a deleted namespace")
 	rootCmd.AddCommand(cleanupCmd)
 }
 
@@ -75,3 +121,288 @@ func validateCleanupArgs(args []string) error {
 	}
 	return nil
 }
+
+func validateCleanupFlags(olderThan time.Duration, selector string, concurrency int) error {
+	if olderThan < 0 {
+		return fmt.Errorf("older-than must be >= 0 (got %s)", olderThan)
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return fmt.Errorf("invalid selector %q: %v", selector, err)
+	}
+	if concurrency <= 0 {
+		return fmt.Errorf("concurrency must be > 0 (got %d)", concurrency)
+	}
+	return nil
+}
+
+// cleanupLabelSelector restricts the user's selector to namespaces created by
+// pvcbench, and to a single run when runID is set.
+func cleanupLabelSelector(runID, selector string) string {
+	requirements := []string{k8s.ManagedBySelector}
+	if runID != "" {
+		requirements = append(requirements, k8s.LabelRunID+"="+runID)
+	}
+	if selector != "" {
+		requirements = append(requirements, selector)
+	}
+	return strings.Join(requirements, ",")
+}
+
+// legacyNamespace matches the names of namespaces created before run
+// namespaces were labelled: pvcbench-<unix> for benchmark runs and
+// pvcbench-<unix>-r<replicas>-t<trial> for saturation trials.
+var legacyNamespace = regexp.MustCompile(`^` + k8s.NamespacePrefix + `[0-9]+(-r[0-9]+-t[0-9]+)?$`)
+
+// listCleanupNamespaces returns the namespaces matching cleanupLabelSelector.
+// Without --run-id or --selector it adds the unlabelled namespaces of earlier
+// versions, which can only be recognised by name.
+func listCleanupNamespaces(ctx context.Context, client kubernetes.Interface, runID, selector string) ([]corev1.Namespace, error) {
+	labelled, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: cleanupLabelSelector(runID, selector),
+	})
+	if err != nil {
+		return nil, err
+	}
+	namespaces := labelled.Items
+	if runID != "" || selector != "" {
+		return namespaces, nil
+	}
+	all, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range all.Items {
+		if _, ok := ns.Labels[k8s.LabelManagedBy]; !ok && legacyNamespace.MatchString(ns.Name) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces, nil
+}
+
+// selectCleanupNamespaces applies the age filter. Namespaces already being
+// deleted are included so that cleanup can wait for them or force them.
+func selectCleanupNamespaces(namespaces []corev1.Namespace, olderThan time.Duration, now time.Time) []string {
+	var names []string
+	for _, ns := range namespaces {
+		if olderThan > 0 && now.Sub(ns.CreationTimestamp.Time) < olderThan {
+			continue
+		}
+		names = append(names, ns.Name)
+	}
+	return names
+}
+
+func printCleanupPlan(ctx context.Context, client kubernetes.Interface, names []string) error {
+	fmt.Printf("Would delete %d namespaces:\n", len(names))
+	fmt.Printf("%-32s %12s %6s %6s\n", "Namespace", "StatefulSets", "Pods", "PVCs")
+	var total k8s.NamespaceResources
+	for _, name := range names {
+		counts, err := k8s.CountNamespaceResources(ctx, client, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-32s %12d %6d %6d\n", name, counts.StatefulSets, counts.Pods, counts.PVCs)
+		total.StatefulSets += counts.StatefulSets
+		total.Pods += counts.Pods
+		total.PVCs += counts.PVCs
+	}
+	fmt.Printf("%-32s %12d %6d %6d\n", "Total", total.StatefulSets, total.Pods, total.PVCs)
+	return nil
+}
+
+// deleteNamespaces deletes up to concurrency namespaces at a time and keeps
+// going past failures, returning them all at the end.
+func deleteNamespaces(ctx context.Context, client kubernetes.Interface, dyn dynamic.Interface, names []string, concurrency int) error {
+	var (
+		mu        sync.Mutex
+		errs      []error
+		summaries []k8s.ForceDeleteSummary
+		wg        sync.WaitGroup
+	)
+	sem := make(chan struct{}, concurrency)
+	for _, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			summary, err := deleteCleanupNamespace(ctx, client, dyn, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if summary != nil {
+				summaries = append(summaries, *summary)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", name, err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(summaries) > 0 {
+		printForceDeleteSummaries(summaries)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to clean up %d of %d namespaces:\n%w", len(errs), len(names), errors.Join(errs...))
+	}
+	return nil
+}
+
+// deleteCleanupNamespace returns a summary of what was force-removed when
+// --force is set, including on failure.
+func deleteCleanupNamespace(ctx context.Context, client kubernetes.Interface, dyn dynamic.Interface, name string) (*k8s.ForceDeleteSummary, error) {
+	logger := logging.GetLogger().With(logging.StringField("name", name))
+
+	logger.Info("deleting namespace")
+	if err := k8s.DeleteNamespace(ctx, client, name); err != nil {
+		logger.Error("failed to delete namespace", logging.ErrorField(err))
+		return nil, err
+	}
+	if forceDelete {
+		summary, err := k8s.ForceDeleteNamespace(ctx, client, dyn, name, k8s.ForceDeleteOptions{Wait: cleanupTimeout, DeletePVs: cleanupDeletePVs})
+		if err != nil {
+			logger.Error("force delete namespace failed", logging.ErrorField(err))
+		}
+		return &summary, err
+	}
+	if err := k8s.WaitForNamespaceDeleted(ctx, client, name, cleanupTimeout); err != nil {
+		logger.Error("waiting for namespace deletion failed", logging.ErrorField(err))
+		var timeoutErr *k8s.PhaseTimeoutError
+		if !errors.As(err, &timeoutErr) {
+			return nil, err
+		}
+		report, diagErr := k8s.DiagnoseNamespaceTermination(ctx, client, dyn, name)
+		if diagErr != nil {
+			logger.Error("failed to diagnose namespace termination", logging.ErrorField(diagErr))
+			return nil, err
+		}
+		report.Log(logger)
+		return nil, fmt.Errorf("%v (%s; rerun with --force to remove finalizers)", err, formatRemaining(report.Counts()))
+	}
+	return nil, nil
+}
+
+func printForceDeleteSummaries(summaries []k8s.ForceDeleteSummary) {
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Namespace < summaries[j].Namespace })
+	fmt.Println("Force-removed:")
+	for _, s := range summaries {
+		fmt.Printf("  %s: namespace finalized=%t\n", s.Namespace, s.NamespaceFinalized)
+		for _, o := range s.Unblocked {
+			fmt.Printf("    %s/%s finalizers %s\n", o.Resource, o.Name, strings.Join(o.Finalizers, ","))
+		}
+		for _, o := range s.Orphaned {
+			fmt.Printf("    %s/%s orphaned\n", o.Resource, o.Name)
+		}
+		for _, pv := range s.DeletedPVs {
+			fmt.Printf("    persistentvolumes/%s deleted\n", pv)
+		}
+	}
+}
+
+// cleanupPVCandidates lists the PVs that --pvs would delete. With planned set
+// the cleaned namespaces are treated as already gone.
+func cleanupPVCandidates(ctx context.Context, client kubernetes.Interface, cleaned []string, planned bool) ([]corev1.PersistentVolume, error) {
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	cleanedSet := make(map[string]bool, len(cleaned))
+	for _, name := range cleaned {
+		cleanedSet[name] = true
+	}
+	existing := map[string]bool{}
+	for _, ns := range namespaces.Items {
+		if planned && cleanedSet[ns.Name] {
+			continue
+		}
+		existing[ns.Name] = true
+	}
+	pvs, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list persistent volumes: %v", err)
+	}
+	return selectCleanupPVs(pvs.Items, existing, cleanedSet, orphanNamespaceFilter(cleanupRunID, cleanupSelector, cleanupOlderThan)), nil
+}
+
+// orphanNamespaceFilter matches namespaces deleted before this cleanup. Their
+// labels and age are gone, so they can only be matched by name: all benchmark
+// namespaces without filters, the run's namespace with --run-id, and none
+// with --selector or --older-than.
+func orphanNamespaceFilter(runID, selector string, olderThan time.Duration) func(string) bool {
+	return func(namespace string) bool {
+		switch {
+		case runID != "":
+			return namespace == k8s.RunNamespace(runID)
+		case selector != "" || olderThan > 0:
+			return false
+		default:
+			return strings.HasPrefix(namespace, k8s.NamespacePrefix)
+		}
+	}
+}
+
+// selectCleanupPVs returns the PVs claimed from namespaces that no longer
+// exist and were either cleaned up now or match the orphan filter.
+func selectCleanupPVs(pvs []corev1.PersistentVolume, existing, cleaned map[string]bool, orphan func(string) bool) []corev1.PersistentVolume {
+	var selected []corev1.PersistentVolume
+	for _, pv := range pvs {
+		if pv.Spec.ClaimRef == nil {
+			continue
+		}
+		ns := pv.Spec.ClaimRef.Namespace
+		if existing[ns] || !(cleaned[ns] || orphan(ns)) {
+			continue
+		}
+		selected = append(selected, pv)
+	}
+	return selected
+}
+
+func printPVCleanupPlan(pvs []corev1.PersistentVolume) {
+	if len(pvs) == 0 {
+		fmt.Println("No leftover PersistentVolumes.")
+		return
+	}
+	fmt.Printf("Would delete %d PersistentVolumes:\n", len(pvs))
+	fmt.Printf("%-48s %-10s %-8s %s\n", "PersistentVolume", "Phase", "Reclaim", "Claim")
+	for _, pv := range pvs {
+		fmt.Printf("%-48s %-10s %-8s %s/%s\n", pv.Name, pv.Status.Phase, pv.Spec.PersistentVolumeReclaimPolicy, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+	}
+}
+
+func deleteCleanupPVs(ctx context.Context, client kubernetes.Interface, cleaned []string) error {
+	logger := logging.GetLogger()
+	pvs, err := cleanupPVCandidates(ctx, client, cleaned, false)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range pvs {
+		logger.Info("deleting persistent volume", logging.StringField("name", pvs[i].Name), logging.StringField("claim_namespace", pvs[i].Spec.ClaimRef.Namespace))
+		if err := k8s.DeletePersistentVolume(ctx, client, &pvs[i]); err != nil {
+			logger.Error("failed to delete persistent volume", logging.StringField("name", pvs[i].Name), logging.ErrorField(err))
+			errs = append(errs, err)
+		}
+	}
+	fmt.Printf("Deleted %d of %d leftover PersistentVolumes.\n", len(pvs)-len(errs), len(pvs))
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to delete %d of %d persistent volumes:\n%w", len(errs), len(pvs), errors.Join(errs...))
+	}
+	return nil
+}
+
+func formatRemaining(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "no objects remaining"
+	}
+	resources := make([]string, 0, len(counts))
+	for resource := range counts {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	parts := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[resource], resource))
+	}
+	return strings.Join(parts, ", ") + " remaining"
+}
@@ -1,6 +1,24 @@
 package main
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
 
 func TestValidateCleanupArgs(t *testing.T) {
 	if err := validateCleanupArgs(nil); err != nil {
@@ -10,3 +28,226 @@ func TestValidateCleanupArgs(t *testing.T) {
 		t.Fatalf("expected error for extra args")
 	}
 }
+
+func TestValidateCleanupFlags(t *testing.T) {
+	if err := validateCleanupFlags(time.Hour, "pvcbench.io/scenario=burst", 4); err != nil {
+		t.Fatalf("expected flags to be valid: %v", err)
+	}
+	if err := validateCleanupFlags(-time.Hour, "", 1); err == nil {
+		t.Fatalf("expected error for negative older-than")
+	}
+	if err := validateCleanupFlags(0, "a in (", 1); err == nil {
+		t.Fatalf("expected error for invalid selector")
+	}
+	if err := validateCleanupFlags(0, "", 0); err == nil {
+		t.Fatalf("expected error for zero concurrency")
+	}
+}
+
+func TestCleanupLabelSelector(t *testing.T) {
+	if got := cleanupLabelSelector("", ""); got != "app.kubernetes.io/managed-by=pvcbench" {
+		t.Fatalf("unexpected default selector: %s", got)
+	}
+	if got := cleanupLabelSelector("abc", "team=storage"); got != "app.kubernetes.io/managed-by=pvcbench,pvcbench.io/run-id=abc,team=storage" {
+		t.Fatalf("unexpected selector: %s", got)
+	}
+}
+
+func TestListCleanupNamespaces(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	managed := map[string]string{k8s.LabelManagedBy: k8s.ManagedByValue, k8s.LabelRunID: "abc"}
+	client := fake.NewSimpleClientset(
+		namespace("pvcbench-abc", managed),
+		namespace("pvcbench-1700000000", nil),
+		namespace("pvcbench-1700000000-r100-t2", nil),
+		namespace("pvcbench-dev", nil),
+		namespace("default", nil),
+	)
+	names := func(namespaces []corev1.Namespace) string {
+		var out []string
+		for _, ns := range namespaces {
+			out = append(out, ns.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	namespaces, err := listCleanupNamespaces(context.Background(), client, "", "")
+	if err != nil {
+		t.Fatalf("listCleanupNamespaces error: %v", err)
+	}
+	if got := names(namespaces); got != "pvcbench-abc,pvcbench-1700000000,pvcbench-1700000000-r100-t2" {
+		t.Fatalf("expected labelled and legacy namespaces, got %s", got)
+	}
+	namespaces, err = listCleanupNamespaces(context.Background(), client, "abc", "")
+	if err != nil {
+		t.Fatalf("listCleanupNamespaces error: %v", err)
+	}
+	if got := names(namespaces); got != "pvcbench-abc" {
+		t.Fatalf("expected only the run's namespace with --run-id, got %s", got)
+	}
+}
+
+func TestSelectCleanupNamespaces(t *testing.T) {
+	now := time.Now()
+	namespace := func(name string, age time.Duration) corev1.Namespace {
+		return corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))}}
+	}
+	namespaces := []corev1.Namespace{
+		namespace("pvcbench-old", 3*time.Hour),
+		namespace("pvcbench-new", time.Minute),
+	}
+
+	if got := strings.Join(selectCleanupNamespaces(namespaces, 0, now), ","); got != "pvcbench-old,pvcbench-new" {
+		t.Fatalf("unexpected selection without filters: %s", got)
+	}
+	if got := strings.Join(selectCleanupNamespaces(namespaces, time.Hour, now), ","); got != "pvcbench-old" {
+		t.Fatalf("unexpected selection with older-than: %s", got)
+	}
+}
+
+func TestDeleteNamespacesContinuesPastFailures(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-1"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-2"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-3"}},
+	)
+	client.PrependReactor("delete", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.DeleteAction).GetName() == "pvcbench-2" {
+			return true, nil, apierrors.NewForbidden(corev1.Resource("namespaces"), "pvcbench-2", errors.New("denied"))
+		}
+		return false, nil, nil
+	})
+
+	err := deleteNamespaces(context.Background(), client, nil, []string{"pvcbench-1", "pvcbench-2", "pvcbench-3"}, 2)
+	if err == nil || !strings.Contains(err.Error(), "failed to clean up 1 of 3 namespaces") || !strings.Contains(err.Error(), "pvcbench-2") {
+		t.Fatalf("expected aggregated error for pvcbench-2, got %v", err)
+	}
+	for _, name := range []string{"pvcbench-1", "pvcbench-3"} {
+		if _, err := client.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Fatalf("expected %s to be deleted, got %v", name, err)
+		}
+	}
+}
+
+func TestFormatRemaining(t *testing.T) {
+	if got := formatRemaining(nil); got != "no objects remaining" {
+		t.Fatalf("unexpected empty summary: %q", got)
+	}
+	got := formatRemaining(map[string]int{"pods": 2, "persistentvolumeclaims": 3})
+	if got != "3 persistentvolumeclaims, 2 pods remaining" {
+		t.Fatalf("unexpected summary: %q", got)
+	}
+}
+
+func TestPrintForceDeleteSummaries(t *testing.T) {
+	summaries := []k8s.ForceDeleteSummary{
+		{
+			Namespace:          "pvcbench-b",
+			NamespaceFinalized: true,
+			Unblocked:          []k8s.RemainingObject{{Resource: "persistentvolumeclaims", Name: "data-0", Finalizers: []string{"kubernetes.io/pvc-protection"}}},
+			Orphaned:           []k8s.RemainingObject{{Resource: "pods", Name: "pvcbench-sts-0"}},
+			DeletedPVs:         []string{"pv-1"},
+		},
+		{Namespace: "pvcbench-a"},
+	}
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	os.Stdout = w
+
+	printForceDeleteSummaries(summaries)
+
+	_ = w.Close()
+	os.Stdout = origStdout
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	output := buf.String()
+
+	for _, expected := range []string{"persistentvolumeclaims/data-0 finalizers kubernetes.io/pvc-protection", "persistentvolumes/pv-1 deleted", "pods/pvcbench-sts-0 orphaned", "pvcbench-b: namespace finalized=true"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Index(output, "pvcbench-a") > strings.Index(output, "pvcbench-b") {
+		t.Fatalf("expected summaries sorted by namespace, got:\n%s", output)
+	}
+}
+
+func TestOrphanNamespaceFilter(t *testing.T) {
+	runNS := k8s.RunNamespace("run-1")
+	if !orphanNamespaceFilter("", "", 0)(runNS) || orphanNamespaceFilter("", "", 0)("default") {
+		t.Fatalf("expected unfiltered cleanup to match only benchmark namespaces")
+	}
+	if !orphanNamespaceFilter("run-1", "", 0)(runNS) || orphanNamespaceFilter("run-1", "", 0)(k8s.RunNamespace("run-2")) {
+		t.Fatalf("expected --run-id to match only its namespace")
+	}
+	if orphanNamespaceFilter("", "team=storage", 0)(runNS) || orphanNamespaceFilter("", "", time.Hour)(runNS) {
+		t.Fatalf("expected label and age filters to exclude orphans")
+	}
+}
+
+func TestSelectCleanupPVs(t *testing.T) {
+	pv := func(name, claimNamespace string) corev1.PersistentVolume {
+		p := corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if claimNamespace != "" {
+			p.Spec.ClaimRef = &corev1.ObjectReference{Namespace: claimNamespace, Name: "data"}
+		}
+		return p
+	}
+	pvs := []corev1.PersistentVolume{
+		pv("pv-cleaned", "pvcbench-a"),
+		pv("pv-orphan", "pvcbench-old"),
+		pv("pv-live", "pvcbench-live"),
+		pv("pv-other", "default"),
+		pv("pv-unclaimed", ""),
+	}
+	existing := map[string]bool{"pvcbench-live": true, "default": true}
+	cleaned := map[string]bool{"pvcbench-a": true}
+
+	selected := selectCleanupPVs(pvs, existing, cleaned, orphanNamespaceFilter("", "", 0))
+	var names []string
+	for _, p := range selected {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "pv-cleaned,pv-orphan" {
+		t.Fatalf("unexpected selection: %v", names)
+	}
+
+	selected = selectCleanupPVs(pvs, existing, cleaned, orphanNamespaceFilter("", "", time.Hour))
+	if len(selected) != 1 || selected[0].Name != "pv-cleaned" {
+		t.Fatalf("expected only the cleaned namespace's pv with --older-than, got %v", selected)
+	}
+}
+
+func TestDeleteCleanupPVs(t *testing.T) {
+	retained := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1", Annotations: map[string]string{"pv.kubernetes.io/provisioned-by": "k8s.io/minikube-hostpath"}},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef:                      &corev1.ObjectReference{Namespace: "pvcbench-old", Name: "data"},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased},
+	}
+	client := fake.NewSimpleClientset(retained)
+	var patched bool
+	client.PrependReactor("patch", "persistentvolumes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patched = true
+		return false, nil, nil
+	})
+
+	if err := deleteCleanupPVs(context.Background(), client, nil); err != nil {
+		t.Fatalf("deleteCleanupPVs error: %v", err)
+	}
+	if !patched {
+		t.Fatalf("expected the Retain reclaim policy to be switched before deletion")
+	}
+	if _, err := client.CoreV1().PersistentVolumes().Get(context.Background(), "pv-1", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected pv-1 to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+
+	"pvc-protection-bench/pkg/deploy"
+	"pvc-protection-bench/pkg/k8s"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	deployNamespace string
+	deployName      string
+	deployImage     string
+)
+
+// localOnlyFlags only make sense on the machine running deploy render and are
+// not passed on to the Job.
+var localOnlyFlags = map[string]bool{
+	"namespace":        true,
+	"name":             true,
+	"image":            true,
+	"kubeconfig":       true,
+	"context":          true,
+	"allowed-contexts": true,
+	"yes":              true,
+	"history-dir":      true,
+}
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Generate manifests for running pvcbench inside the cluster",
+}
+
+var deployRenderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the ServiceAccount, RBAC and Job manifests for a benchmark run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateBenchmarkInputs(scenario, replicas, pvcSize, batchSize, deleteInterval, pvcPollInterval); err != nil {
+			return err
+		}
+		if err := validateShards(shards, replicas); err != nil {
+			return err
+		}
+		out, err := deploy.Render(deploy.RenderOptions{
+			Namespace:   deployNamespace,
+			Name:        deployName,
+			Image:       deployImage,
+			Args:        jobArgs(cmd.Flags()),
+			MetricsPort: metricsPort,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(out))
+		return nil
+	},
+}
+
+// jobArgs builds the benchmark command line for the Job from the scenario and
+// replica count plus every other flag set on deploy render.
+func jobArgs(flags *pflag.FlagSet) []string {
+	args := []string{
+		"benchmark",
+		"--allowed-contexts=" + k8s.InClusterContext,
+		"--no-history",
+		fmt.Sprintf("--scenario=%s", scenario),
+		fmt.Sprintf("--replicas=%d", replicas),
+	}
+	flags.Visit(func(f *pflag.Flag) {
+		if localOnlyFlags[f.Name] || f.Name == "scenario" || f.Name == "replicas" {
+			return
+		}
+		// Slice values print as [a,b], which would be parsed back as the
+		// elements "[a" and "b]", so they are passed one element per flag.
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range slice.GetSlice() {
+				args = append(args, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+			return
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+	return args
+}
+
+func init() {
+	addScenarioFlags(deployRenderCmd)
+	deployRenderCmd.Flags().Int32Var(&replicas, "replicas", 100, "Number of replicas")
+	deployRenderCmd.Flags().StringVar(&deployNamespace, "namespace", "pvcbench", "Namespace for the ServiceAccount and Job")
+	deployRenderCmd.Flags().StringVar(&deployName, "name", "pvcbench", "Name of the ServiceAccount, ClusterRole, binding and Job")
+	deployRenderCmd.Flags().StringVar(&deployImage, "image", "pvcbench:latest", "Container image with the pvcbench binary")
+
+	deployCmd.AddCommand(deployRenderCmd)
+	rootCmd.AddCommand(deployCmd)
+}
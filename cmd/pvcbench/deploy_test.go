@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestJobArgs(t *testing.T) {
+	flags := pflag.NewFlagSet("render", pflag.ContinueOnError)
+	var image string
+	var interval time.Duration
+	flags.StringVar(&image, "image", "pvcbench:latest", "")
+	flags.DurationVar(&interval, "delete-interval", 5*time.Second, "")
+	flags.DurationVar(&interval, "drain-timeout", 30*time.Minute, "")
+	var nodes []string
+	flags.StringSliceVar(&nodes, "static-pv-nodes", nil, "")
+	if err := flags.Parse([]string{"--image=pvcbench:dev", "--delete-interval=2s", "--static-pv-nodes=node-a,node-b"}); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+
+	scenario, replicas = "staggered", 50
+	got := strings.Join(jobArgs(flags), " ")
+	want := "benchmark --allowed-contexts=in-cluster --no-history --scenario=staggered --replicas=50 --delete-interval=2s --static-pv-nodes=node-a --static-pv-nodes=node-b"
+	if got != want {
+		t.Fatalf("jobArgs = %q, want %q", got, want)
+	}
+
+	// The Job must parse the slice back into the same elements.
+	parsed := pflag.NewFlagSet("job", pflag.ContinueOnError)
+	var jobNodes []string
+	parsed.StringSliceVar(&jobNodes, "static-pv-nodes", nil, "")
+	parsed.ParseErrorsAllowlist.UnknownFlags = true
+	if err := parsed.Parse(jobArgs(flags)); err != nil {
+		t.Fatalf("parse job args: %v", err)
+	}
+	if strings.Join(jobNodes, "|") != "node-a|node-b" {
+		t.Fatalf("expected the job to get node-a and node-b, got %q", jobNodes)
+	}
+}
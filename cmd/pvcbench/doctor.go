@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"pvc-protection-bench/pkg/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorOpts doctor.Options
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the cluster is ready for a benchmark run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if doctorOpts.Replicas <= 0 {
+			return fmt.Errorf("replicas must be > 0 (got %d)", doctorOpts.Replicas)
+		}
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+
+		doctorOpts.MetricsPort = metricsPort
+		checks := doctor.Run(context.Background(), client, doctorOpts)
+		printDoctorChecks(checks)
+		if failed := doctor.Failed(checks); failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+		}
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().Int32Var(&doctorOpts.Replicas, "replicas", 100, "Number of replicas the run will create")
+	doctorCmd.Flags().StringVar(&doctorOpts.PVCSize, "pvc-size", "100Mi", "PVC size the run will request")
+	doctorCmd.Flags().StringVar(&doctorOpts.Namespace, "namespace", "", "Existing namespace the run will use, checked for quotas and limit ranges")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func printDoctorChecks(checks []doctor.Check) {
+	fmt.Printf("%-14s %-6s %s\n", "Check", "Status", "Detail")
+	for _, c := range checks {
+		fmt.Printf("%-14s %-6s %s\n", c.Name, c.Status, c.Detail)
+	}
+}
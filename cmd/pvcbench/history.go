@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"pvc-protection-bench/pkg/history"
+	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/scenarios"
+	"pvc-protection-bench/pkg/stats"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyScenario   string
+	historyK8sVersion string
+	historyTags       map[string]string
+	historyParams     map[string]string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Query results of previous benchmark runs",
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded runs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := history.NewStore(historyDir).List(historyFilter())
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("No runs recorded.")
+			return nil
+		}
+		fmt.Printf("%-36s %-20s %-10s %-10s %s\n", "RUN ID", "TIME", "SCENARIO", "K8S", "PARAMS / TAGS")
+		for _, e := range entries {
+			fmt.Printf("%-36s %-20s %-10s %-10s %s %s\n",
+				e.RunID, e.Timestamp.Local().Format("2006-01-02 15:04:05"), e.Scenario, e.KubernetesVersion,
+				formatKV(e.Params), formatKV(e.Tags))
+		}
+		return nil
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <run-id>",
+	Short: "Show a recorded run",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rec, err := history.NewStore(historyDir).Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Run ID: %s\n", rec.RunID)
+		fmt.Printf("Time: %s\n", rec.Timestamp.Local().Format(time.RFC3339))
+		fmt.Printf("Scenario: %s\n", rec.Scenario)
+		fmt.Printf("Kubernetes Version: %s\n", rec.KubernetesVersion)
+		fmt.Printf("Params: %s\n", formatKV(rec.Params))
+		if len(rec.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", formatKV(rec.Tags))
+		}
+		if rec.Operator != "" {
+			fmt.Printf("Operator: %s\n", rec.Operator)
+		}
+		if rec.ToolVersion != "" {
+			fmt.Printf("Tool Version: %s\n", rec.ToolVersion)
+		}
+		if rec.LockHolder != "" {
+			fmt.Printf("Lock Holder: %s\n", rec.LockHolder)
+		}
+		fmt.Printf("Total Duration: %s\n", rec.TotalDuration)
+		fmt.Printf("PVC Delete Latency:\n")
+		fmt.Printf("  Count:  %d\n", rec.Latency.Count)
+		fmt.Printf("  Min:    %s\n", rec.Latency.Min)
+		fmt.Printf("  Avg:    %s\n", rec.Latency.Mean)
+		fmt.Printf("  StdDev: %s\n", rec.Latency.StdDev)
+		fmt.Printf("  p50:    %s\n", rec.Latency.P50)
+		fmt.Printf("  p90:    %s\n", rec.Latency.P90)
+		fmt.Printf("  p99:    %s\n", rec.Latency.P99)
+		fmt.Printf("  p99.9:  %s\n", rec.Latency.P999)
+		fmt.Printf("  Max:    %s\n", rec.Latency.Max)
+		if len(rec.Batches) > 0 {
+			fmt.Printf("Per-Batch Latency:\n")
+			for _, b := range rec.Batches {
+				fmt.Printf("  batch %d: count=%d p50=%s p99=%s drain=%s\n", b.Batch, b.Count, b.P50, b.P99, b.TimeToDrain)
+			}
+		}
+		return nil
+	},
+}
+
+var historyTrendCmd = &cobra.Command{
+	Use:   "trend",
+	Short: "Print p50/p99 over time for runs matching a parameter set",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := history.NewStore(historyDir).Trend(historyFilter())
+		if err != nil {
+			return err
+		}
+		printTrend(records)
+		return nil
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{historyListCmd, historyTrendCmd} {
+		c.Flags().StringVar(&historyScenario, "scenario", "", "Only include runs of this scenario")
+		c.Flags().StringVar(&historyK8sVersion, "k8s-version", "", "Only include runs against this Kubernetes version")
+		c.Flags().StringToStringVar(&historyTags, "tag", nil, "Only include runs with these tags (key=value)")
+		c.Flags().StringToStringVar(&historyParams, "param", nil, "Only include runs with these parameters, e.g. replicas=100")
+	}
+	historyCmd.AddCommand(historyListCmd, historyShowCmd, historyTrendCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+func historyFilter() history.Filter {
+	return history.Filter{
+		Scenario:          historyScenario,
+		KubernetesVersion: historyK8sVersion,
+		Tags:              historyTags,
+		Params:            historyParams,
+	}
+}
+
+func summaryParams(inputs SummaryInputs) map[string]string {
+	params := map[string]string{
+		"scenario":          inputs.Scenario,
+		"replicas":          fmt.Sprintf("%d", inputs.Replicas),
+		"pvc_size":          inputs.PVCSize,
+		"pvc_poll_interval": inputs.PVCPollInterval.String(),
+	}
+	if inputs.Shards > 1 {
+		params["shards"] = fmt.Sprintf("%d", inputs.Shards)
+	}
+	if inputs.Scenario == "staggered" {
+		params["delete_batch_size"] = fmt.Sprintf("%d", inputs.DeleteBatchSize)
+		params["delete_interval"] = inputs.DeleteInterval.String()
+	}
+	return params
+}
+
+func newHistoryRecord(inputs SummaryInputs, totalDuration time.Duration, latencies []time.Duration, batches []scenarios.BatchSummary, tags map[string]string) history.Record {
+	summary := stats.Summarize(latencies)
+	record := history.Record{
+		RunID:             inputs.RunID,
+		Timestamp:         time.Now().UTC(),
+		Scenario:          inputs.Scenario,
+		KubernetesVersion: inputs.KubernetesVersion,
+		Tags:              tags,
+		Params:            summaryParams(inputs),
+		TotalDuration:     totalDuration,
+		LockHolder:        inputs.LockHolder,
+		ToolVersion:       toolVersion(),
+		Operator:          k8s.Operator(),
+		Latency: history.LatencySummary{
+			Count:  summary.Count,
+			Min:    summary.Min,
+			Mean:   summary.Mean,
+			StdDev: summary.StdDev,
+			P50:    summary.P50,
+			P90:    summary.P90,
+			P99:    summary.P99,
+			P999:   summary.P999,
+			Max:    summary.Max,
+		},
+	}
+	if len(batches) > 1 {
+		for _, b := range batches {
+			record.Batches = append(record.Batches, history.BatchSummary(b))
+		}
+	}
+	return record
+}
+
+func printTrend(records []history.Record) {
+	if len(records) == 0 {
+		fmt.Println("No runs recorded.")
+		return
+	}
+	fmt.Printf("%-20s %-24s %-10s %6s %14s %14s %10s\n", "TIME", "RUN ID", "K8S", "COUNT", "p50", "p99", "p99 Δ")
+	baseline := records[0].Latency.P99
+	for _, rec := range records {
+		delta := "-"
+		if baseline > 0 {
+			delta = fmt.Sprintf("%+.1f%%", (float64(rec.Latency.P99)-float64(baseline))/float64(baseline)*100)
+		}
+		fmt.Printf("%-20s %-24s %-10s %6d %14s %14s %10s\n",
+			rec.Timestamp.Local().Format("2006-01-02 15:04:05"), rec.RunID, rec.KubernetesVersion,
+			rec.Latency.Count, rec.Latency.P50, rec.Latency.P99, delta)
+	}
+}
+
+func formatKV(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+m[k])
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"pvc-protection-bench/pkg/history"
+	"pvc-protection-bench/pkg/scenarios"
+)
+
+func TestNewHistoryRecord(t *testing.T) {
+	inputs := SummaryInputs{
+		RunID:             "run-1",
+		Scenario:          "staggered",
+		Replicas:          10,
+		PVCSize:           "100Mi",
+		DeleteBatchSize:   5,
+		DeleteInterval:    time.Second,
+		PVCPollInterval:   100 * time.Millisecond,
+		KubernetesVersion: "v1.32.0",
+	}
+	latencies := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+
+	batches := []scenarios.BatchSummary{
+		{Batch: 0, Count: 2, P50: time.Second, P99: 2 * time.Second, TimeToDrain: 3 * time.Second},
+		{Batch: 1, Count: 1, P50: 3 * time.Second, P99: 3 * time.Second, TimeToDrain: 4 * time.Second},
+	}
+
+	rec := newHistoryRecord(inputs, 5*time.Second, latencies, batches, map[string]string{"team": "storage"})
+	if rec.RunID != "run-1" || rec.KubernetesVersion != "v1.32.0" {
+		t.Fatalf("unexpected record identity: %+v", rec)
+	}
+	if rec.Params["replicas"] != "10" || rec.Params["delete_batch_size"] != "5" || rec.Params["delete_interval"] != "1s" {
+		t.Fatalf("unexpected params: %v", rec.Params)
+	}
+	if rec.Latency.Count != 3 || rec.Latency.P50 != 2*time.Second || rec.Latency.Max != 3*time.Second {
+		t.Fatalf("unexpected latency summary: %+v", rec.Latency)
+	}
+	if len(rec.Batches) != 2 || rec.Batches[1].Batch != 1 || rec.Batches[1].TimeToDrain != 4*time.Second {
+		t.Fatalf("unexpected batches: %+v", rec.Batches)
+	}
+	if rec := newHistoryRecord(inputs, time.Second, latencies, batches[:1], nil); rec.Batches != nil {
+		t.Fatalf("expected a single batch to be left out, got %+v", rec.Batches)
+	}
+
+	burst := summaryParams(SummaryInputs{Scenario: "burst", Replicas: 10})
+	if _, ok := burst["delete_batch_size"]; ok {
+		t.Fatalf("expected staggered-only params to be omitted for burst")
+	}
+}
+
+func TestPrintTrend(t *testing.T) {
+	records := []history.Record{
+		{RunID: "run-a", Timestamp: time.Now(), Latency: history.LatencySummary{Count: 10, P50: time.Second, P99: 2 * time.Second}},
+		{RunID: "run-b", Timestamp: time.Now(), Latency: history.LatencySummary{Count: 10, P50: time.Second, P99: 3 * time.Second}},
+	}
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	os.Stdout = w
+
+	printTrend(records)
+
+	_ = w.Close()
+	os.Stdout = origStdout
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	output := buf.String()
+
+	for _, expected := range []string{"run-a", "run-b", "+50.0%"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
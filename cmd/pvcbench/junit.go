@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func buildJUnitReport(inputs SummaryInputs, totalDuration time.Duration, runErr error, results []AssertionResult) junitTestSuites {
+	className := fmt.Sprintf("pvcbench.%s", inputs.Scenario)
+	suite := junitTestSuite{
+		Name: fmt.Sprintf("pvcbench-%s-%d", inputs.Scenario, inputs.Replicas),
+		Time: fmt.Sprintf("%.3f", totalDuration.Seconds()),
+	}
+
+	runCase := junitTestCase{Name: "run", ClassName: className}
+	if runErr != nil {
+		runCase.Error = &junitFailure{Message: "benchmark run failed", Text: runErr.Error()}
+		suite.Errors++
+	}
+	suite.Cases = append(suite.Cases, runCase)
+
+	for _, r := range results {
+		tc := junitTestCase{Name: r.Assertion.Expr, ClassName: className}
+		switch {
+		case r.Err != nil:
+			tc.Failure = &junitFailure{Message: r.Err.Error(), Text: r.Err.Error()}
+			suite.Failures++
+		case !r.Passed:
+			msg := fmt.Sprintf("expected %s, actual %s", r.Assertion.Expr, r.Actual)
+			tc.Failure = &junitFailure{Message: msg, Text: msg}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+func writeJUnitReport(path string, report junitTestSuites) error {
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode junit report: %v", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	data = append(data, '\n')
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write junit report %s: %v", path, err)
+	}
+	return nil
+}
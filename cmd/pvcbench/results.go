@@ -2,26 +2,50 @@ package main
 
 import (
 	"fmt"
-	"sort"
+	"strings"
 	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/scenarios"
+	"pvc-protection-bench/pkg/stats"
+)
+
+const (
+	histogramBuckets = 10
+	histogramWidth   = 40
 )
 
+var cdfPercentiles = []float64{10, 25, 50, 75, 90, 95, 99, 99.9, 100}
+
 type SummaryInputs struct {
+	RunID             string
 	Scenario          string
 	Replicas          int32
 	PVCSize           string
 	DeleteBatchSize   int32
 	DeleteInterval    time.Duration
 	PVCPollInterval   time.Duration
+	Shards            int
 	KubernetesVersion string
+	LockHolder        string
+	Aborted           bool
 }
 
 func printSummary(totalDuration time.Duration, latencies []time.Duration, inputs SummaryInputs) {
 	fmt.Println("\n=== Benchmark Summary ===")
+	if inputs.Aborted {
+		fmt.Println("Status: ABORTED (partial results)")
+	}
+	if inputs.RunID != "" {
+		fmt.Printf("Run ID: %s\n", inputs.RunID)
+	}
 	fmt.Printf("Total Duration: %s\n", totalDuration)
 	fmt.Printf("Scenario: %s\n", inputs.Scenario)
 	fmt.Printf("Replicas: %d\n", inputs.Replicas)
 	fmt.Printf("PVC Size: %s\n", inputs.PVCSize)
+	if inputs.Shards > 1 {
+		fmt.Printf("Shards: %d\n", inputs.Shards)
+	}
 	if inputs.KubernetesVersion != "" {
 		fmt.Printf("Kubernetes Version: %s\n", inputs.KubernetesVersion)
 	}
@@ -30,48 +54,71 @@ func printSummary(totalDuration time.Duration, latencies []time.Duration, inputs
 		fmt.Printf("Delete Interval: %s\n", inputs.DeleteInterval)
 	}
 	fmt.Printf("PVC Poll Interval: %s\n", inputs.PVCPollInterval)
+	if inputs.LockHolder != "" {
+		fmt.Printf("Lock Holder: %s\n", inputs.LockHolder)
+	}
 
 	if len(latencies) == 0 {
 		fmt.Println("No PVC deletions recorded.")
 		return
 	}
 
-	sort.Slice(latencies, func(i, j int) bool {
-		return latencies[i] < latencies[j]
-	})
-
-	p50 := percentile(latencies, 50)
-	p90 := percentile(latencies, 90)
-	p99 := percentile(latencies, 99)
-	avg := average(latencies)
+	summary := stats.Summarize(latencies)
 
 	fmt.Printf("PVC Delete Latency:\n")
-	fmt.Printf("  Count: %d\n", len(latencies))
-	fmt.Printf("  Avg:   %s\n", avg)
-	fmt.Printf("  p50:   %s\n", p50)
-	fmt.Printf("  p90:   %s\n", p90)
-	fmt.Printf("  p99:   %s\n", p99)
+	fmt.Printf("  Count:  %d\n", summary.Count)
+	fmt.Printf("  Min:    %s\n", summary.Min)
+	fmt.Printf("  Avg:    %s\n", summary.Mean)
+	fmt.Printf("  StdDev: %s\n", summary.StdDev)
+	fmt.Printf("  p50:    %s (95%% CI %s - %s)\n", summary.P50, summary.P50CI.Low, summary.P50CI.High)
+	fmt.Printf("  p90:    %s\n", summary.P90)
+	fmt.Printf("  p99:    %s (95%% CI %s - %s)\n", summary.P99, summary.P99CI.Low, summary.P99CI.High)
+	fmt.Printf("  p99.9:  %s\n", summary.P999)
+	fmt.Printf("  Max:    %s\n", summary.Max)
+	fmt.Printf("  Outliers (outside %s - %s): %d\n", summary.LowerFence, summary.UpperFence, summary.Outliers)
+	fmt.Printf("Histogram:\n%s", stats.Histogram(latencies, histogramBuckets, histogramWidth))
+	fmt.Printf("CDF:\n%s", stats.CDF(latencies, cdfPercentiles, histogramWidth))
 	fmt.Println("==========================")
 }
 
-func percentile(latencies []time.Duration, p int) time.Duration {
-	if len(latencies) == 0 {
-		return 0
+func printBatchSummary(batches []scenarios.BatchSummary) {
+	if len(batches) < 2 {
+		return
 	}
-	idx := (p * len(latencies)) / 100
-	if idx >= len(latencies) {
-		idx = len(latencies) - 1
+	fmt.Println("\n=== Per-Batch Latency ===")
+	fmt.Printf("%-6s %6s %14s %14s %14s\n", "Batch", "Count", "p50", "p99", "Time to Drain")
+	for _, b := range batches {
+		fmt.Printf("%-6d %6d %14s %14s %14s\n", b.Batch, b.Count, b.P50, b.P99, b.TimeToDrain)
 	}
-	return latencies[idx]
+	fmt.Println("=========================")
 }
 
-func average(latencies []time.Duration) time.Duration {
-	if len(latencies) == 0 {
-		return 0
+func printExcludedPVCs(reports []k8s.StuckPVCReport) {
+	if len(reports) == 0 {
+		return
+	}
+	fmt.Printf("\n=== Excluded Stuck PVCs (%d) ===\n", len(reports))
+	for _, r := range reports {
+		fmt.Printf("%s: stuck for %s, finalizers=%s, pods=%s\n", r.Name, r.StuckFor.Round(time.Second), strings.Join(r.Finalizers, ","), strings.Join(r.Pods, ","))
+	}
+	fmt.Println("=========================")
+}
+
+func printPVReport(report k8s.PVReport) {
+	if report.Tracked == 0 {
+		return
+	}
+	fmt.Println("\n=== PersistentVolumes ===")
+	fmt.Printf("Tracked: %d, Deleted: %d, Released: %d, Remaining: %d\n", report.Tracked, report.Deleted, len(report.Released), len(report.Remaining))
+	if len(report.Latencies) > 0 {
+		summary := stats.Summarize(report.Latencies)
+		fmt.Printf("PV Delete Latency (after PVC deletion): p50 %s, p99 %s, max %s\n", summary.P50, summary.P99, summary.Max)
+	}
+	if len(report.Released) > 0 {
+		fmt.Printf("Released: %s\n", strings.Join(report.Released, ","))
 	}
-	var total time.Duration
-	for _, l := range latencies {
-		total += l
+	if len(report.Remaining) > 0 {
+		fmt.Printf("Remaining: %s\n", strings.Join(report.Remaining, ","))
 	}
-	return total / time.Duration(len(latencies))
+	fmt.Println("=========================")
 }
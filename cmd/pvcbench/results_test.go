@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"pvc-protection-bench/pkg/scenarios"
 )
 
 func TestPrintSummaryIncludesInputs(t *testing.T) {
@@ -44,9 +46,59 @@ func TestPrintSummaryIncludesInputs(t *testing.T) {
 		"Kubernetes Version: v1.30.11",
 		"PVC Poll Interval: 100ms",
 		"PVC Delete Latency:",
+		"p99.9:",
+		"Histogram:",
+		"CDF:",
 	} {
 		if !strings.Contains(output, expected) {
 			t.Fatalf("expected output to contain %q, got:\n%s", expected, output)
 		}
 	}
 }
+
+func TestPrintBatchSummary(t *testing.T) {
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	os.Stdout = w
+
+	printBatchSummary([]scenarios.BatchSummary{
+		{Batch: 0, Count: 10, P50: time.Second, P99: 2 * time.Second, TimeToDrain: 3 * time.Second},
+		{Batch: 1, Count: 10, P50: 2 * time.Second, P99: 4 * time.Second, TimeToDrain: 5 * time.Second},
+	})
+
+	_ = w.Close()
+	os.Stdout = origStdout
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	output := buf.String()
+
+	for _, expected := range []string{"Per-Batch Latency", "Time to Drain", "5s"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestPrintSummaryMarksAborted(t *testing.T) {
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	os.Stdout = w
+
+	printSummary(time.Second, []time.Duration{time.Second}, SummaryInputs{Scenario: "burst", Replicas: 2, Aborted: true})
+
+	_ = w.Close()
+	os.Stdout = origStdout
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	if !strings.Contains(buf.String(), "Status: ABORTED (partial results)") {
+		t.Fatalf("expected aborted marker, got:\n%s", buf.String())
+	}
+}
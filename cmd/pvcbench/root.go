@@ -1,18 +1,36 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"runtime/debug"
+	"strings"
 
+	"pvc-protection-bench/pkg/history"
+	"pvc-protection-bench/pkg/k8s"
 	"pvc-protection-bench/pkg/logging"
 
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version string
+
 var (
 	clientQPS   float32
 	clientBurst int
 	metricsPort int
+	historyDir  string
+
+	kubeconfig      string
+	kubeContext     string
+	allowedContexts []string
+	assumeYes       bool
 )
 
 var rootCmd = &cobra.Command{
@@ -24,14 +42,89 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.Version = toolVersion()
 	rootCmd.PersistentFlags().Float32Var(&clientQPS, "client-qps", 200, "Kubernetes client QPS")
 	rootCmd.PersistentFlags().IntVar(&clientBurst, "client-burst", 400, "Kubernetes client Burst")
 	rootCmd.PersistentFlags().IntVar(&metricsPort, "metrics-port", 8080, "Port for Prometheus metrics")
+	rootCmd.PersistentFlags().StringVar(&historyDir, "history-dir", history.DefaultDir(), "Directory of the local results history store")
+
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (defaults to $KUBECONFIG or ~/.kube/config)")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "context", "", "Kubeconfig context to use (defaults to the current context)")
+	rootCmd.PersistentFlags().StringSliceVar(&allowedContexts, "allowed-contexts", k8s.DefaultAllowedContexts(), "Contexts or API server URLs that may be used without confirmation (defaults to $"+k8s.AllowedContextsEnv+" or minikube)")
+	rootCmd.PersistentFlags().BoolVar(&assumeYes, "yes", false, "Use a context outside --allowed-contexts without asking for confirmation")
+}
+
+func newClient() (*kubernetes.Clientset, error) {
+	return k8s.NewClient(clientOptions())
+}
+
+// newClients returns a clientset and a dynamic client for commands that work
+// with arbitrary resource types.
+func newClients() (*kubernetes.Clientset, dynamic.Interface, error) {
+	restConfig, err := k8s.NewRESTConfig(clientOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create clientset: %v", err)
+	}
+	dyn, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+	return client, dyn, nil
+}
+
+func clientOptions() k8s.ClientOptions {
+	return k8s.ClientOptions{
+		Kubeconfig:      kubeconfig,
+		Context:         kubeContext,
+		QPS:             clientQPS,
+		Burst:           clientBurst,
+		AllowedContexts: allowedContexts,
+		Confirm: func(context, server string) bool {
+			if assumeYes {
+				logging.GetLogger().Warn("using context outside the allowlist", logging.StringField("context", context), logging.StringField("server", server))
+				return true
+			}
+			if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+				return false
+			}
+			return confirmContext(os.Stdin, os.Stdout, context, server)
+		},
+	}
+}
+
+// confirmContext asks the user to type the context name before running
+// against a cluster that is not in the allowlist.
+func confirmContext(in io.Reader, out io.Writer, context, server string) bool {
+	fmt.Fprintf(out, "Context %q (%s) is not in the allowed contexts.\n", context, server)
+	fmt.Fprintf(out, "pvcbench creates and deletes StatefulSets, PVCs and namespaces. Type the context name to continue: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	return strings.TrimSpace(line) == context
+}
+
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
 }
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
+		var assertErr *assertionFailedError
+		if errors.As(err, &assertErr) {
+			os.Exit(exitCodeAssertionFailed)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfirmContext(t *testing.T) {
+	var out bytes.Buffer
+	if !confirmContext(strings.NewReader("kind-perf\n"), &out, "kind-perf", "https://127.0.0.1:6443") {
+		t.Fatalf("expected typed context name to confirm")
+	}
+	if !strings.Contains(out.String(), "kind-perf") {
+		t.Fatalf("expected prompt to name the context, got %q", out.String())
+	}
+	if confirmContext(strings.NewReader("yes\n"), &out, "kind-perf", "") {
+		t.Fatalf("expected other input to be rejected")
+	}
+	if confirmContext(strings.NewReader(""), &out, "kind-perf", "") {
+		t.Fatalf("expected empty input to be rejected")
+	}
+}
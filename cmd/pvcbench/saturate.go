@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/logging"
+	"pvc-protection-bench/pkg/metrics"
+	"pvc-protection-bench/pkg/saturation"
+	"pvc-protection-bench/pkg/stats"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+var saturateOpts saturation.Options
+
+var saturateCmd = &cobra.Command{
+	Use:   "saturate",
+	Short: "Search for the largest load whose PVC delete p99 stays under a target",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := saturateOpts.Validate(); err != nil {
+			return err
+		}
+		if err := validateBenchmarkInputs(scenario, saturateOpts.MinReplicas, pvcSize, batchSize, deleteInterval, pvcPollInterval); err != nil {
+			return err
+		}
+		if err := validateTimeouts(runTimeout, phaseTimeouts, cleanupTimeout); err != nil {
+			return err
+		}
+		if err := validateStuckOptions(stuckThreshold, stuckPolicy); err != nil {
+			return err
+		}
+		if err := validateRetryPolicy(retryPolicy); err != nil {
+			return err
+		}
+		if err := validateStaticPVOptions(staticPVs); err != nil {
+			return err
+		}
+		if err := validateShards(shards, saturateOpts.MinReplicas); err != nil {
+			return err
+		}
+		if err := validateSafetyMargin(safetyMargin); err != nil {
+			return err
+		}
+
+		client, err := newClient()
+		if err != nil {
+			return err
+		}
+		metrics.StartMetricsServer(metricsPort, "")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := withRunTimeout(ctx, runTimeout)
+		defer cancel()
+
+		// Every trial gets a fresh namespace, so only the largest load is
+		// checked and quotas do not apply.
+		largest := k8s.StatefulSetConfig{Replicas: saturateOpts.MaxReplicas, PVCSize: pvcSize, StaticPVs: staticPVConfig(), Shards: shards}
+		if err := checkResourceBudget(ctx, client, largest); err != nil {
+			return err
+		}
+
+		lock, err := acquireRunLock(ctx, client)
+		if err != nil {
+			return err
+		}
+		defer releaseRunLock(lock)
+		ctx, cancelRun := watchRunLock(ctx, lock)
+		defer cancelRun()
+
+		report, err := saturation.Search(ctx, saturateOpts, func(ctx context.Context, replicas int32, trial int) (time.Duration, error) {
+			return runSaturationTrial(ctx, client, replicas, trial)
+		})
+		printSaturationReport(report, saturateOpts)
+		if lockErr := runLockError(lock); lockErr != nil {
+			return lockErr
+		}
+		return err
+	},
+}
+
+func init() {
+	addScenarioFlags(saturateCmd)
+	saturateCmd.Flags().StringVar(&saturateOpts.Mode, "mode", saturation.ModeBinary, "Search mode: binary (bisect between min and max) or ramp (increase by step until the target is exceeded)")
+	saturateCmd.Flags().Int32Var(&saturateOpts.MinReplicas, "min-replicas", 10, "Smallest replica count to try")
+	saturateCmd.Flags().Int32Var(&saturateOpts.MaxReplicas, "max-replicas", 500, "Largest replica count to try")
+	saturateCmd.Flags().Int32Var(&saturateOpts.Step, "step", 10, "Ramp increment, and the resolution at which binary search stops")
+	saturateCmd.Flags().IntVar(&saturateOpts.Trials, "trials", 3, "Trials per load level; the median p99 is compared against the target")
+	saturateCmd.Flags().DurationVar(&saturateOpts.TargetP99, "target-p99", 5*time.Second, "PVC delete p99 SLO")
+	rootCmd.AddCommand(saturateCmd)
+}
+
+func runSaturationTrial(ctx context.Context, client kubernetes.Interface, replicas int32, trial int) (time.Duration, error) {
+	logger := logging.GetLogger()
+	runID := k8s.NewRunID()
+	namespace := k8s.RunNamespace(runID)
+	meta := runMetadata(SummaryInputs{
+		RunID:           runID,
+		Scenario:        scenario,
+		Replicas:        replicas,
+		PVCSize:         pvcSize,
+		DeleteBatchSize: batchSize,
+		DeleteInterval:  deleteInterval,
+		PVCPollInterval: pvcPollInterval,
+		Shards:          shards,
+	})
+	meta.Params["trial"] = fmt.Sprintf("%d", trial+1)
+	config := k8s.StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: namespace,
+		Replicas:  replicas,
+		PVCSize:   pvcSize,
+		Run:       meta,
+		StaticPVs: staticPVConfig(),
+		Shards:    shards,
+	}
+
+	logger.Info("starting saturation trial",
+		logging.StringField("namespace", namespace),
+		logging.StringField("replicas", fmt.Sprintf("%d", replicas)),
+		logging.StringField("trial", fmt.Sprintf("%d", trial+1)),
+	)
+	result, runErr := runScenario(ctx, client, scenario, config)
+
+	if err := teardownRun(client, config, true); err != nil {
+		logger.Error("failed to delete trial namespace", logging.StringField("name", namespace), logging.ErrorField(err))
+		if runErr == nil {
+			runErr = err
+		}
+	}
+	if runErr != nil {
+		return 0, runErr
+	}
+
+	latencies := result.Latencies()
+	if len(latencies) == 0 {
+		return 0, fmt.Errorf("no PVC deletions recorded")
+	}
+	p99 := stats.Summarize(latencies).P99
+	logger.Info("saturation trial completed",
+		logging.StringField("replicas", fmt.Sprintf("%d", replicas)),
+		logging.StringField("p99", p99.String()),
+	)
+	return p99, nil
+}
+
+func printSaturationReport(report saturation.Report, opts saturation.Options) {
+	fmt.Println("\n=== Saturation Report ===")
+	fmt.Printf("Scenario: %s\n", scenario)
+	fmt.Printf("Mode: %s\n", opts.Mode)
+	fmt.Printf("Target p99: %s\n", opts.TargetP99)
+	fmt.Printf("%-10s %-14s %-8s %s\n", "Replicas", "Median p99", "Result", "Trial p99s")
+	for _, p := range report.Points {
+		result := "FAIL"
+		if p.Passed {
+			result = "PASS"
+		}
+		trials := make([]string, 0, len(p.TrialP99))
+		for _, t := range p.TrialP99 {
+			trials = append(trials, t.String())
+		}
+		fmt.Printf("%-10d %-14s %-8s %s\n", p.Replicas, p.P99, result, strings.Join(trials, ", "))
+	}
+	if report.Knee > 0 {
+		fmt.Printf("Knee: %d replicas\n", report.Knee)
+	} else {
+		fmt.Println("Knee: none (minimum load exceeded the target)")
+	}
+	fmt.Println("=========================")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"pvc-protection-bench/pkg/saturation"
+)
+
+func TestPrintSaturationReport(t *testing.T) {
+	report := saturation.Report{
+		Knee: 50,
+		Points: []saturation.Point{
+			{Replicas: 50, TrialP99: []time.Duration{4 * time.Second}, P99: 4 * time.Second, Passed: true},
+			{Replicas: 100, TrialP99: []time.Duration{9 * time.Second}, P99: 9 * time.Second},
+		},
+	}
+	opts := saturation.Options{Mode: saturation.ModeBinary, TargetP99: 5 * time.Second}
+
+	origStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	os.Stdout = w
+
+	printSaturationReport(report, opts)
+
+	_ = w.Close()
+	os.Stdout = origStdout
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	output := buf.String()
+
+	for _, expected := range []string{"Target p99: 5s", "PASS", "FAIL", "Knee: 50 replicas"} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
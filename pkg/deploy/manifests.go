@@ -0,0 +1,105 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+type RenderOptions struct {
+	Namespace   string
+	Name        string
+	Image       string
+	Args        []string
+	MetricsPort int
+}
+
+// ClusterRules are the permissions pvcbench needs for a run, its run lock and
+// its cleanup.
+// Pods/proxy is only read for the controller-manager workqueue depth. PersistentVolumes and PVCs are created for static PV runs,
+// and the PVs released for reuse and deleted at teardown.
+// StatefulSets and their scale are server-side applied, which needs patch.
+// Nodes, quotas and storage capacity are read to check that a run fits the
+// cluster.
+var ClusterRules = []rbacv1.PolicyRule{
+	{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: []string{"get", "list", "create", "delete", "patch"}},
+	{APIGroups: []string{"apps"}, Resources: []string{"statefulsets"}, Verbs: []string{"get", "create", "patch", "delete"}},
+	{APIGroups: []string{"apps"}, Resources: []string{"statefulsets/scale"}, Verbs: []string{"patch"}},
+	{APIGroups: []string{""}, Resources: []string{"persistentvolumeclaims"}, Verbs: []string{"get", "list", "create", "patch"}},
+	{APIGroups: []string{""}, Resources: []string{"pods", "events"}, Verbs: []string{"list"}},
+	{APIGroups: []string{""}, Resources: []string{"pods/proxy"}, Verbs: []string{"get"}},
+	{APIGroups: []string{"coordination.k8s.io"}, Resources: []string{"leases"}, Verbs: []string{"get", "create", "update"}},
+	{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"list"}},
+	{APIGroups: []string{""}, Resources: []string{"persistentvolumes"}, Verbs: []string{"get", "create", "patch", "delete"}},
+	{APIGroups: []string{""}, Resources: []string{"resourcequotas"}, Verbs: []string{"list"}},
+	{APIGroups: []string{"storage.k8s.io"}, Resources: []string{"storageclasses", "csistoragecapacities"}, Verbs: []string{"list"}},
+}
+
+// Objects returns the ServiceAccount, ClusterRole, ClusterRoleBinding and Job
+// that run pvcbench inside the cluster.
+func Objects(opts RenderOptions) []runtime.Object {
+	labels := map[string]string{"app.kubernetes.io/name": "pvcbench"}
+	meta := metav1.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace, Labels: labels}
+	clusterMeta := metav1.ObjectMeta{Name: opts.Name, Labels: labels}
+	backoffLimit := int32(0)
+
+	return []runtime.Object{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta,
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: clusterMeta,
+			Rules:      ClusterRules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: clusterMeta,
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: opts.Name},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: opts.Name, Namespace: opts.Namespace}},
+		},
+		&batchv1.Job{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+			ObjectMeta: meta,
+			Spec: batchv1.JobSpec{
+				BackoffLimit: &backoffLimit,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{
+						ServiceAccountName: opts.Name,
+						RestartPolicy:      corev1.RestartPolicyNever,
+						Containers: []corev1.Container{{
+							Name:  "pvcbench",
+							Image: opts.Image,
+							Args:  opts.Args,
+							Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: int32(opts.MetricsPort)}},
+						}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Render returns the objects as a multi-document YAML stream.
+func Render(opts RenderOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, obj := range Objects(opts) {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %T: %v", obj, err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,44 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRender(t *testing.T) {
+	out, err := Render(RenderOptions{
+		Namespace:   "pvcbench-system",
+		Name:        "pvcbench",
+		Image:       "pvcbench:dev",
+		Args:        []string{"benchmark", "--scenario=burst"},
+		MetricsPort: 8080,
+	})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+
+	docs := strings.Split(string(out), "---\n")
+	if len(docs) != 4 {
+		t.Fatalf("expected 4 documents, got %d", len(docs))
+	}
+	for i, kind := range []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Job"} {
+		if !strings.Contains(docs[i], "kind: "+kind) {
+			t.Fatalf("expected document %d to be a %s:\n%s", i, kind, docs[i])
+		}
+	}
+
+	var job batchv1.Job
+	if err := yaml.Unmarshal([]byte(docs[3]), &job); err != nil {
+		t.Fatalf("unmarshal job: %v", err)
+	}
+	pod := job.Spec.Template.Spec
+	if pod.ServiceAccountName != "pvcbench" || pod.Containers[0].Image != "pvcbench:dev" {
+		t.Fatalf("unexpected pod spec: %+v", pod)
+	}
+	if got := strings.Join(pod.Containers[0].Args, " "); got != "benchmark --scenario=burst" {
+		t.Fatalf("unexpected args: %s", got)
+	}
+}
@@ -0,0 +1,313 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"pvc-protection-bench/pkg/deploy"
+	"pvc-protection-bench/pkg/k8s"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+)
+
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+type Options struct {
+	Replicas int32
+	PVCSize  string
+	// Namespace is checked for ResourceQuotas and LimitRanges. Empty means
+	// the run creates a fresh namespace.
+	Namespace   string
+	MetricsPort int
+}
+
+// optionalResources are only used for diagnostics, so missing permissions
+// degrade the run instead of breaking it. Nodes are not among them: every run
+// lists them to check that it fits the cluster. Neither are PersistentVolumes,
+// which are tracked after their PVCs and created and deleted for static PVs.
+var optionalResources = map[string]bool{
+	"pods/proxy": true,
+}
+
+// Run executes every check in order. Errors talking to the API server are
+// reported as failed checks rather than aborting the remaining checks.
+func Run(ctx context.Context, client kubernetes.Interface, opts Options) []Check {
+	provisioner, storageClass := checkDefaultStorageClass(ctx, client)
+	return []Check{
+		storageClass,
+		checkProvisioner(ctx, client, provisioner),
+		checkNodeCapacity(ctx, client, opts.Replicas),
+		checkQuotas(ctx, client, opts),
+		checkRBAC(ctx, client),
+		checkPauseImage(ctx, client),
+		checkMetricsPort(opts.MetricsPort),
+	}
+}
+
+func Failed(checks []Check) int {
+	failed := 0
+	for _, c := range checks {
+		if c.Status == Fail {
+			failed++
+		}
+	}
+	return failed
+}
+
+func checkDefaultStorageClass(ctx context.Context, client kubernetes.Interface) (string, Check) {
+	check := Check{Name: "storage-class"}
+	classes, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("failed to list storage classes: %v", err)
+		return "", check
+	}
+	var defaults []string
+	provisioner := ""
+	for _, sc := range classes.Items {
+		if k8s.IsDefaultStorageClass(sc.Annotations) {
+			defaults = append(defaults, sc.Name)
+			provisioner = sc.Provisioner
+		}
+	}
+	switch len(defaults) {
+	case 0:
+		check.Status, check.Detail = Fail, "no default StorageClass; PVCs from the StatefulSet will stay Pending"
+	case 1:
+		check.Status, check.Detail = Pass, fmt.Sprintf("%s (provisioner %s)", defaults[0], provisioner)
+	default:
+		check.Status, check.Detail = Warn, fmt.Sprintf("multiple default StorageClasses: %s", strings.Join(defaults, ", "))
+	}
+	return provisioner, check
+}
+
+func checkProvisioner(ctx context.Context, client kubernetes.Interface, provisioner string) Check {
+	check := Check{Name: "provisioner"}
+	if provisioner == "" {
+		check.Status, check.Detail = Fail, "no default provisioner to check"
+		return check
+	}
+
+	events, err := client.CoreV1().Events(metav1.NamespaceAll).List(ctx, metav1.ListOptions{FieldSelector: "reason=ProvisioningFailed"})
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("failed to list events: %v", err)
+		return check
+	}
+	failures := 0
+	for _, e := range events.Items {
+		if e.Reason == "ProvisioningFailed" {
+			failures++
+		}
+	}
+	if failures > 0 {
+		check.Status, check.Detail = Warn, fmt.Sprintf("%d ProvisioningFailed events for recent claims", failures)
+		return check
+	}
+
+	if _, err := client.StorageV1().CSIDrivers().Get(ctx, provisioner, metav1.GetOptions{}); err == nil {
+		check.Status, check.Detail = Pass, fmt.Sprintf("CSI driver %s registered", provisioner)
+		return check
+	} else if !apierrors.IsNotFound(err) {
+		check.Status, check.Detail = Fail, fmt.Sprintf("failed to get CSI driver %s: %v", provisioner, err)
+		return check
+	}
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("failed to list pods: %v", err)
+		return check
+	}
+	for _, pod := range pods.Items {
+		if strings.Contains(pod.Name, "provisioner") && pod.Status.Phase == corev1.PodRunning {
+			check.Status, check.Detail = Pass, fmt.Sprintf("provisioner pod %s/%s running", pod.Namespace, pod.Name)
+			return check
+		}
+	}
+	check.Status, check.Detail = Warn, fmt.Sprintf("no CSI driver or running provisioner pod found for %s", provisioner)
+	return check
+}
+
+func checkNodeCapacity(ctx context.Context, client kubernetes.Interface, replicas int32) Check {
+	check := Check{Name: "node-capacity"}
+	schedulable, free, err := k8s.FreePodSlots(ctx, client)
+	if err != nil {
+		check.Status, check.Detail = Fail, err.Error()
+		return check
+	}
+	detail := fmt.Sprintf("%d schedulable nodes, %d free pod slots for %d replicas", schedulable, free, replicas)
+	switch {
+	case free < int64(replicas):
+		check.Status = Fail
+	case free < int64(replicas)*5/4:
+		check.Status, detail = Warn, detail+" (less than 25% headroom)"
+	default:
+		check.Status = Pass
+	}
+	check.Detail = detail
+	return check
+}
+
+func checkQuotas(ctx context.Context, client kubernetes.Interface, opts Options) Check {
+	check := Check{Name: "quotas"}
+	if opts.Namespace == "" {
+		check.Status, check.Detail = Pass, "runs use a fresh namespace"
+		return check
+	}
+	size, err := resource.ParseQuantity(opts.PVCSize)
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("invalid pvc size %q: %v", opts.PVCSize, err)
+		return check
+	}
+	budget, err := k8s.EstimateBudget(k8s.StatefulSetConfig{Replicas: opts.Replicas, PVCSize: opts.PVCSize})
+	if err != nil {
+		check.Status, check.Detail = Fail, err.Error()
+		return check
+	}
+
+	var problems []string
+	quotas, err := k8s.QuotaLimits(ctx, client, opts.Namespace, budget)
+	if err != nil {
+		check.Status, check.Detail = Fail, err.Error()
+		return check
+	}
+	for _, quota := range quotas {
+		if quota.Exceeded(0) {
+			problems = append(problems, fmt.Sprintf("%s allows %s more %s, need %s", quota.Source, quota.Available.String(), quota.Resource, quota.Needed.String()))
+		}
+	}
+
+	limitRanges, err := client.CoreV1().LimitRanges(opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("failed to list limit ranges: %v", err)
+		return check
+	}
+	for _, lr := range limitRanges.Items {
+		for _, item := range lr.Spec.Limits {
+			if item.Type != corev1.LimitTypePersistentVolumeClaim {
+				continue
+			}
+			if min, ok := item.Min[corev1.ResourceStorage]; ok && size.Cmp(min) < 0 {
+				problems = append(problems, fmt.Sprintf("LimitRange %s requires PVCs of at least %s", lr.Name, min.String()))
+			}
+			if max, ok := item.Max[corev1.ResourceStorage]; ok && size.Cmp(max) > 0 {
+				problems = append(problems, fmt.Sprintf("LimitRange %s allows PVCs of at most %s", lr.Name, max.String()))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		check.Status, check.Detail = Fail, strings.Join(problems, "; ")
+		return check
+	}
+	check.Status, check.Detail = Pass, fmt.Sprintf("%d quota limits and %d limit ranges in %s allow the run", len(quotas), len(limitRanges.Items), opts.Namespace)
+	return check
+}
+
+func checkRBAC(ctx context.Context, client kubernetes.Interface) Check {
+	check := Check{Name: "rbac"}
+	var denied, deniedOptional []string
+	granted := 0
+	for _, rule := range deploy.ClusterRules {
+		for _, group := range rule.APIGroups {
+			for _, res := range rule.Resources {
+				resourceName, subresource, _ := strings.Cut(res, "/")
+				for _, verb := range rule.Verbs {
+					review := &authorizationv1.SelfSubjectAccessReview{
+						Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+							ResourceAttributes: &authorizationv1.ResourceAttributes{
+								Group:       group,
+								Resource:    resourceName,
+								Subresource: subresource,
+								Verb:        verb,
+							},
+						},
+					}
+					result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+					if err != nil {
+						check.Status, check.Detail = Fail, fmt.Sprintf("failed to review access to %s %s: %v", verb, res, err)
+						return check
+					}
+					switch {
+					case result.Status.Allowed:
+						granted++
+					case optionalResources[res]:
+						deniedOptional = append(deniedOptional, verb+" "+res)
+					default:
+						denied = append(denied, verb+" "+res)
+					}
+				}
+			}
+		}
+	}
+	switch {
+	case len(denied) > 0:
+		check.Status, check.Detail = Fail, "denied: "+strings.Join(append(denied, deniedOptional...), ", ")
+	case len(deniedOptional) > 0:
+		check.Status, check.Detail = Warn, "diagnostics degraded, denied: "+strings.Join(deniedOptional, ", ")
+	default:
+		check.Status, check.Detail = Pass, fmt.Sprintf("%d permissions granted", granted)
+	}
+	return check
+}
+
+func checkPauseImage(ctx context.Context, client kubernetes.Interface) Check {
+	check := Check{Name: "pause-image"}
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("failed to list nodes: %v", err)
+		return check
+	}
+	cached := 0
+	for _, node := range nodes.Items {
+		if nodeHasImage(node, k8s.PauseImage) {
+			cached++
+		}
+	}
+	if cached < len(nodes.Items) {
+		check.Status, check.Detail = Warn, fmt.Sprintf("%s cached on %d/%d nodes; the rest will pull it during setup", k8s.PauseImage, cached, len(nodes.Items))
+		return check
+	}
+	check.Status, check.Detail = Pass, fmt.Sprintf("%s cached on all %d nodes", k8s.PauseImage, len(nodes.Items))
+	return check
+}
+
+func nodeHasImage(node corev1.Node, image string) bool {
+	for _, img := range node.Status.Images {
+		for _, name := range img.Names {
+			if name == image {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func checkMetricsPort(port int) Check {
+	check := Check{Name: "metrics-port"}
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		check.Status, check.Detail = Fail, fmt.Sprintf("port %d unavailable: %v", port, err)
+		return check
+	}
+	ln.Close()
+	check.Status, check.Detail = Pass, fmt.Sprintf("port %d available", port)
+	return check
+}
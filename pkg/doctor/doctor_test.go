@@ -0,0 +1,133 @@
+package doctor
+
+import (
+	"context"
+	"testing"
+
+	"pvc-protection-bench/pkg/k8s"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testNode(name string, pods int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(pods, resource.DecimalSI)},
+			Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			Images:      []corev1.ContainerImage{{Names: []string{k8s.PauseImage}}},
+		},
+	}
+}
+
+func allowAccess(denied map[string]bool) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		res := attrs.Resource
+		if attrs.Subresource != "" {
+			res += "/" + attrs.Subresource
+		}
+		review.Status.Allowed = !denied[attrs.Verb+" "+res]
+		return true, review, nil
+	}
+}
+
+func checkByName(t *testing.T, checks []Check, name string) Check {
+	t.Helper()
+	for _, c := range checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("check %s not found", name)
+	return Check{}
+}
+
+func TestRunHealthyCluster(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: "standard", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+			Provisioner: "k8s.io/minikube-hostpath",
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "storage-provisioner", Namespace: "kube-system"},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+		testNode("node-a", 110),
+		testNode("node-b", 110),
+	)
+	client.PrependReactor("create", "selfsubjectaccessreviews", allowAccess(nil))
+
+	checks := Run(context.Background(), client, Options{Replicas: 100, PVCSize: "100Mi", MetricsPort: 0})
+	for _, c := range checks {
+		if c.Status != Pass {
+			t.Fatalf("expected %s to pass, got %s: %s", c.Name, c.Status, c.Detail)
+		}
+	}
+	if Failed(checks) != 0 {
+		t.Fatalf("expected no failures")
+	}
+}
+
+func TestRunReportsProblems(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		testNode("node-a", 50),
+		&corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "small", Namespace: "bench"},
+			Spec:       corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePersistentVolumeClaims: resource.MustParse("10")}},
+		},
+	)
+	client.PrependReactor("create", "selfsubjectaccessreviews", allowAccess(map[string]bool{
+		"delete namespaces": true,
+		"get pods/proxy":    true,
+	}))
+
+	checks := Run(context.Background(), client, Options{Replicas: 100, PVCSize: "100Mi", Namespace: "bench", MetricsPort: 0})
+	for name, want := range map[string]Status{
+		"storage-class": Fail,
+		"provisioner":   Fail,
+		"node-capacity": Fail,
+		"quotas":        Fail,
+		"rbac":          Fail,
+	} {
+		if got := checkByName(t, checks, name); got.Status != want {
+			t.Fatalf("expected %s to be %s, got %s: %s", name, want, got.Status, got.Detail)
+		}
+	}
+	if Failed(checks) != 5 {
+		t.Fatalf("expected 5 failures, got %d", Failed(checks))
+	}
+}
+
+func TestCheckRBACOptionalPermissionsWarn(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", allowAccess(map[string]bool{"get pods/proxy": true}))
+	if got := checkRBAC(context.Background(), client); got.Status != Warn {
+		t.Fatalf("expected denied diagnostics permission to warn, got %s: %s", got.Status, got.Detail)
+	}
+}
+
+func TestCheckRBACNodesRequired(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", allowAccess(map[string]bool{"list nodes": true}))
+	if got := checkRBAC(context.Background(), client); got.Status != Fail {
+		t.Fatalf("expected denied list nodes to fail, since the budget check needs it, got %s: %s", got.Status, got.Detail)
+	}
+}
+
+func TestCheckRBACPersistentVolumesRequired(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", allowAccess(map[string]bool{"delete persistentvolumes": true}))
+	if got := checkRBAC(context.Background(), client); got.Status != Fail {
+		t.Fatalf("expected denied persistentvolume access to fail, since static PV runs need it, got %s: %s", got.Status, got.Detail)
+	}
+}
@@ -0,0 +1,289 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	indexFile = "index.json"
+	runsDir   = "runs"
+)
+
+type LatencySummary struct {
+	Count  int           `json:"count"`
+	Min    time.Duration `json:"min"`
+	Mean   time.Duration `json:"mean"`
+	StdDev time.Duration `json:"stddev"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P99    time.Duration `json:"p99"`
+	P999   time.Duration `json:"p999"`
+	Max    time.Duration `json:"max"`
+}
+
+type BatchSummary struct {
+	Batch       int           `json:"batch"`
+	Count       int           `json:"count"`
+	P50         time.Duration `json:"p50"`
+	P99         time.Duration `json:"p99"`
+	TimeToDrain time.Duration `json:"timeToDrain"`
+}
+
+type Record struct {
+	RunID             string            `json:"runId"`
+	Timestamp         time.Time         `json:"timestamp"`
+	Scenario          string            `json:"scenario"`
+	KubernetesVersion string            `json:"kubernetesVersion"`
+	Tags              map[string]string `json:"tags,omitempty"`
+	Params            map[string]string `json:"params"`
+	TotalDuration     time.Duration     `json:"totalDuration"`
+	Latency           LatencySummary    `json:"latency"`
+	// Batches holds the per-batch latencies of staggered runs.
+	Batches     []BatchSummary `json:"batches,omitempty"`
+	LockHolder  string         `json:"lockHolder,omitempty"`
+	ToolVersion string         `json:"toolVersion,omitempty"`
+	Operator    string         `json:"operator,omitempty"`
+}
+
+// IndexEntry is the subset of a Record kept in the index so that listing and
+// filtering do not need to open every run document.
+type IndexEntry struct {
+	RunID             string            `json:"runId"`
+	Timestamp         time.Time         `json:"timestamp"`
+	Scenario          string            `json:"scenario"`
+	KubernetesVersion string            `json:"kubernetesVersion"`
+	Tags              map[string]string `json:"tags,omitempty"`
+	Params            map[string]string `json:"params"`
+	File              string            `json:"file"`
+}
+
+type Filter struct {
+	Scenario          string
+	KubernetesVersion string
+	Tags              map[string]string
+	Params            map[string]string
+}
+
+type Store struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".pvcbench/history"
+	}
+	return filepath.Join(home, ".pvcbench", "history")
+}
+
+func (s *Store) Append(rec Record) error {
+	if rec.RunID == "" {
+		return fmt.Errorf("history record has no run ID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Join(s.Dir, runsDir), 0o755); err != nil {
+		return fmt.Errorf("failed to create history dir %s: %v", s.Dir, err)
+	}
+
+	file := filepath.Join(runsDir, rec.RunID+".json")
+	if err := writeJSON(filepath.Join(s.Dir, file), rec); err != nil {
+		return err
+	}
+
+	index, err := s.readIndex()
+	if err != nil {
+		return err
+	}
+	entry := indexEntry(rec, file)
+	replaced := false
+	for i := range index {
+		if index[i].RunID == rec.RunID {
+			index[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		index = append(index, entry)
+	}
+	return writeJSON(filepath.Join(s.Dir, indexFile), index)
+}
+
+func (s *Store) List(filter Filter) ([]IndexEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, err := s.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]IndexEntry, 0, len(index))
+	for _, e := range index {
+		if filter.Matches(e) {
+			matched = append(matched, e)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Timestamp.Before(matched[j].Timestamp)
+	})
+	return matched, nil
+}
+
+func (s *Store) Get(runID string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, err := s.readIndex()
+	if err != nil {
+		return Record{}, err
+	}
+	for _, e := range index {
+		if e.RunID == runID {
+			return s.readRecord(e.File)
+		}
+	}
+	return Record{}, fmt.Errorf("run %s not found in history %s", runID, s.Dir)
+}
+
+// Trend returns the full records matching filter, oldest first.
+func (s *Store) Trend(filter Filter) ([]Record, error) {
+	entries, err := s.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(entries))
+	for _, e := range entries {
+		rec, err := s.readRecord(e.File)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func (f Filter) Matches(e IndexEntry) bool {
+	if f.Scenario != "" && f.Scenario != e.Scenario {
+		return false
+	}
+	if f.KubernetesVersion != "" && f.KubernetesVersion != e.KubernetesVersion {
+		return false
+	}
+	for k, v := range f.Tags {
+		if e.Tags[k] != v {
+			return false
+		}
+	}
+	for k, v := range f.Params {
+		if e.Params[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// readIndex returns index.json plus an entry for every run document it does
+// not list. The mutex only serialises one process, so two runs appending at
+// once can each rename their own index over the other's; their run documents
+// are both kept, and the lost entry is rebuilt here and written back by the
+// next Append.
+func (s *Store) readIndex() ([]IndexEntry, error) {
+	var index []IndexEntry
+	data, err := os.ReadFile(filepath.Join(s.Dir, indexFile))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read history index: %v", err)
+	default:
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to parse history index: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.Dir, runsDir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history records: %v", err)
+	}
+	indexed := make(map[string]bool, len(index))
+	for _, e := range index {
+		indexed[e.File] = true
+	}
+	for _, path := range files {
+		file := filepath.Join(runsDir, filepath.Base(path))
+		if indexed[file] {
+			continue
+		}
+		rec, err := s.readRecord(file)
+		if err != nil {
+			return nil, err
+		}
+		index = append(index, indexEntry(rec, file))
+	}
+	return index, nil
+}
+
+func indexEntry(rec Record, file string) IndexEntry {
+	return IndexEntry{
+		RunID:             rec.RunID,
+		Timestamp:         rec.Timestamp,
+		Scenario:          rec.Scenario,
+		KubernetesVersion: rec.KubernetesVersion,
+		Tags:              rec.Tags,
+		Params:            rec.Params,
+		File:              file,
+	}
+}
+
+func (s *Store) readRecord(file string) (Record, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, file))
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read history record %s: %v", file, err)
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return Record{}, fmt.Errorf("failed to parse history record %s: %v", file, err)
+	}
+	return rec, nil
+}
+
+// writeJSON writes through a temp file and rename so an interrupted run never
+// leaves a truncated index behind. The temp file is unique so that two
+// processes writing the same path do not rename each other's.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRecord(runID string, ts time.Time, replicas string, p99 time.Duration) Record {
+	return Record{
+		RunID:             runID,
+		Timestamp:         ts,
+		Scenario:          "burst",
+		KubernetesVersion: "v1.32.0",
+		Tags:              map[string]string{"team": "storage"},
+		Params:            map[string]string{"replicas": replicas, "pvc_size": "100Mi"},
+		Latency:           LatencySummary{Count: 10, P99: p99},
+	}
+}
+
+func TestStoreAppendAndGet(t *testing.T) {
+	store := NewStore(t.TempDir())
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if err := store.Append(testRecord("run-a", now, "100", time.Second)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	rec, err := store.Get("run-a")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if rec.Latency.P99 != time.Second || !rec.Timestamp.Equal(now) {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	if _, err := store.Get("missing"); err == nil {
+		t.Fatalf("expected error for unknown run")
+	}
+}
+
+func TestStoreListFiltersAndSorts(t *testing.T) {
+	store := NewStore(t.TempDir())
+	base := time.Now().UTC()
+
+	records := []Record{
+		testRecord("run-c", base.Add(2*time.Hour), "100", 3*time.Second),
+		testRecord("run-a", base, "100", time.Second),
+		testRecord("run-b", base.Add(time.Hour), "200", 2*time.Second),
+	}
+	for _, rec := range records {
+		if err := store.Append(rec); err != nil {
+			t.Fatalf("Append error: %v", err)
+		}
+	}
+
+	all, err := store.List(Filter{})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(all) != 3 || all[0].RunID != "run-a" || all[2].RunID != "run-c" {
+		t.Fatalf("expected 3 runs sorted by time, got %+v", all)
+	}
+
+	trend, err := store.Trend(Filter{Params: map[string]string{"replicas": "100"}, Tags: map[string]string{"team": "storage"}})
+	if err != nil {
+		t.Fatalf("Trend error: %v", err)
+	}
+	if len(trend) != 2 || trend[0].Latency.P99 != time.Second || trend[1].Latency.P99 != 3*time.Second {
+		t.Fatalf("unexpected trend: %+v", trend)
+	}
+
+	none, err := store.List(Filter{Scenario: "staggered"})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("expected no staggered runs, got %d", len(none))
+	}
+}
+
+func TestStoreAppendReplacesExistingRun(t *testing.T) {
+	store := NewStore(t.TempDir())
+	now := time.Now().UTC()
+
+	if err := store.Append(testRecord("run-a", now, "100", time.Second)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+	if err := store.Append(testRecord("run-a", now, "100", 2*time.Second)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+
+	entries, err := store.List(Filter{})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected a single index entry, got %d", len(entries))
+	}
+	rec, err := store.Get("run-a")
+	if err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if rec.Latency.P99 != 2*time.Second {
+		t.Fatalf("expected latest record to win, got %s", rec.Latency.P99)
+	}
+}
+
+func TestStoreEmpty(t *testing.T) {
+	store := NewStore(t.TempDir())
+	entries, err := store.List(Filter{})
+	if err != nil {
+		t.Fatalf("List error on empty store: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected empty store, got %d entries", len(entries))
+	}
+}
+
+func TestStoreRebuildsLostIndexEntries(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	now := time.Now().UTC()
+
+	// Simulate a second process whose index rename lost the race: both run
+	// documents are written but index.json only lists run-a.
+	if err := store.Append(testRecord("run-a", now, "100", time.Second)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if err := store.Append(testRecord("run-b", now.Add(time.Hour), "100", 2*time.Second)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), index, 0o644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	all, err := store.List(Filter{})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(all) != 2 || all[1].RunID != "run-b" {
+		t.Fatalf("expected run-b to be rebuilt from runs/, got %+v", all)
+	}
+	if _, err := store.Get("run-b"); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+
+	if err := store.Append(testRecord("run-c", now.Add(2*time.Hour), "100", 3*time.Second)); err != nil {
+		t.Fatalf("Append error: %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(matches) != 0 {
+		t.Fatalf("expected no temp files left behind, got %v", matches)
+	}
+	index, err = os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if !strings.Contains(string(index), `"run-b"`) {
+		t.Fatalf("expected Append to write run-b back to the index, got %s", index)
+	}
+}
@@ -0,0 +1,273 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ResourceBudget is what a run holds at its peak, when every replica is
+// running with its PVC bound.
+type ResourceBudget struct {
+	Pods    int64
+	PVCs    int64
+	Storage resource.Quantity
+}
+
+func EstimateBudget(config StatefulSetConfig) (ResourceBudget, error) {
+	size, err := resource.ParseQuantity(config.PVCSize)
+	if err != nil {
+		return ResourceBudget{}, fmt.Errorf("invalid pvc size %q: %v", config.PVCSize, err)
+	}
+	replicas := int64(config.Replicas)
+	return ResourceBudget{
+		Pods:    replicas,
+		PVCs:    replicas,
+		Storage: *resource.NewQuantity(size.Value()*replicas, resource.BinarySI),
+	}, nil
+}
+
+func (b ResourceBudget) amount(name corev1.ResourceName) resource.Quantity {
+	switch name {
+	case corev1.ResourcePods:
+		return *resource.NewQuantity(b.Pods, resource.DecimalSI)
+	case corev1.ResourcePersistentVolumeClaims:
+		return *resource.NewQuantity(b.PVCs, resource.DecimalSI)
+	default:
+		return b.Storage.DeepCopy()
+	}
+}
+
+// BudgetLimit is how much of a resource one source leaves for a run.
+type BudgetLimit struct {
+	Resource  corev1.ResourceName
+	Source    string
+	Needed    resource.Quantity
+	Available resource.Quantity
+}
+
+// Exceeded reports whether Needed plus margin, a fraction of Needed, does not
+// fit into Available.
+func (l BudgetLimit) Exceeded(margin float64) bool {
+	return l.Needed.AsApproximateFloat64()*(1+margin) > l.Available.AsApproximateFloat64()
+}
+
+// BudgetLimits compares the budget of config against the free pod slots of
+// schedulable nodes, the storage capacity of the default StorageClass and the
+// ResourceQuotas of config.Namespace. Storage is skipped for static PVs,
+// which share a directory, and namespaced limits when Namespace is empty.
+func BudgetLimits(ctx context.Context, client kubernetes.Interface, config StatefulSetConfig) ([]BudgetLimit, error) {
+	budget, err := EstimateBudget(config)
+	if err != nil {
+		return nil, err
+	}
+
+	_, free, err := FreePodSlots(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	limits := []BudgetLimit{{
+		Resource:  corev1.ResourcePods,
+		Source:    "node allocatable",
+		Needed:    budget.amount(corev1.ResourcePods),
+		Available: *resource.NewQuantity(free, resource.DecimalSI),
+	}}
+
+	if config.StaticPVs == nil {
+		source, capacity, err := storageCapacity(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		if source != "" {
+			limits = append(limits, BudgetLimit{Resource: corev1.ResourceStorage, Source: source, Needed: budget.Storage.DeepCopy(), Available: capacity})
+		}
+	}
+
+	if config.Namespace == "" {
+		return limits, nil
+	}
+	quotas, err := QuotaLimits(ctx, client, config.Namespace, budget)
+	if err != nil {
+		return nil, err
+	}
+	limits = append(limits, quotas...)
+
+	// Pods and PVCs of the run's StatefulSets left in the namespace by an
+	// earlier run are reused or replaced, so what they hold is available.
+	existing, err := existingBudget(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	for i := range limits {
+		limits[i].Available.Add(existing.amount(limits[i].Resource))
+	}
+	return limits, nil
+}
+
+func schedulableNodes(ctx context.Context, client kubernetes.Interface) ([]corev1.Node, error) {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	var schedulable []corev1.Node
+	for _, node := range nodes.Items {
+		if !node.Spec.Unschedulable && nodeReady(node) {
+			schedulable = append(schedulable, node)
+		}
+	}
+	return schedulable, nil
+}
+
+func nodeReady(node corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// FreePodSlots returns the number of schedulable nodes and how many more pods
+// their allocatable pod counts admit.
+func FreePodSlots(ctx context.Context, client kubernetes.Interface) (int, int64, error) {
+	nodes, err := schedulableNodes(ctx, client)
+	if err != nil {
+		return 0, 0, err
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list pods: %v", err)
+	}
+	podsPerNode := map[string]int64{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			podsPerNode[pod.Spec.NodeName]++
+		}
+	}
+	var free int64
+	for _, node := range nodes {
+		if slots := node.Status.Allocatable.Pods().Value() - podsPerNode[node.Name]; slots > 0 {
+			free += slots
+		}
+	}
+	return len(nodes), free, nil
+}
+
+// IsDefaultStorageClass reports whether the annotations mark a StorageClass
+// as the cluster default.
+func IsDefaultStorageClass(annotations map[string]string) bool {
+	return annotations["storageclass.kubernetes.io/is-default-class"] == "true" ||
+		annotations["storageclass.beta.kubernetes.io/is-default-class"] == "true"
+}
+
+// storageCapacity sums the CSIStorageCapacity objects published for the
+// default StorageClass. Provisioners without them, such as the minikube
+// hostpath provisioner, write to node disks, so the allocatable ephemeral
+// storage of schedulable nodes stands in. An empty source means neither is
+// reported.
+func storageCapacity(ctx context.Context, client kubernetes.Interface) (string, resource.Quantity, error) {
+	classes, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", resource.Quantity{}, fmt.Errorf("failed to list storage classes: %v", err)
+	}
+	class := ""
+	for _, sc := range classes.Items {
+		if IsDefaultStorageClass(sc.Annotations) {
+			class = sc.Name
+		}
+	}
+	if class == "" {
+		return "", resource.Quantity{}, nil
+	}
+
+	capacities, err := client.StorageV1().CSIStorageCapacities(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", resource.Quantity{}, fmt.Errorf("failed to list csi storage capacities: %v", err)
+	}
+	total := resource.NewQuantity(0, resource.BinarySI)
+	reported := false
+	for _, c := range capacities.Items {
+		if c.StorageClassName == class && c.Capacity != nil {
+			total.Add(*c.Capacity)
+			reported = true
+		}
+	}
+	if reported {
+		return "CSIStorageCapacity of " + class, *total, nil
+	}
+
+	nodes, err := schedulableNodes(ctx, client)
+	if err != nil {
+		return "", resource.Quantity{}, err
+	}
+	for _, node := range nodes {
+		if ephemeral, ok := node.Status.Allocatable[corev1.ResourceEphemeralStorage]; ok {
+			total.Add(ephemeral)
+			reported = true
+		}
+	}
+	if reported {
+		return "node ephemeral storage", *total, nil
+	}
+	return "", resource.Quantity{}, nil
+}
+
+// QuotaLimits returns, for every ResourceQuota in the namespace, what is left
+// of its pods, persistentvolumeclaims and requests.storage limits.
+func QuotaLimits(ctx context.Context, client kubernetes.Interface, namespace string, budget ResourceBudget) ([]BudgetLimit, error) {
+	quotas, err := client.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource quotas: %v", err)
+	}
+	var limits []BudgetLimit
+	for _, quota := range quotas.Items {
+		for _, name := range []corev1.ResourceName{corev1.ResourcePods, corev1.ResourcePersistentVolumeClaims, corev1.ResourceRequestsStorage} {
+			hard, ok := quota.Status.Hard[name]
+			if !ok {
+				hard, ok = quota.Spec.Hard[name]
+			}
+			if !ok {
+				continue
+			}
+			available := hard.DeepCopy()
+			if used, ok := quota.Status.Used[name]; ok {
+				available.Sub(used)
+			}
+			limits = append(limits, BudgetLimit{Resource: name, Source: "ResourceQuota " + quota.Name, Needed: budget.amount(name), Available: available})
+		}
+	}
+	return limits, nil
+}
+
+func existingBudget(ctx context.Context, client kubernetes.Interface, config StatefulSetConfig) (ResourceBudget, error) {
+	var names []string
+	for _, shard := range config.ShardConfigs() {
+		names = append(names, shard.Name)
+	}
+	selector := fmt.Sprintf("app in (%s)", strings.Join(names, ","))
+	existing := ResourceBudget{Storage: *resource.NewQuantity(0, resource.BinarySI)}
+
+	pods, err := client.CoreV1().Pods(config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return existing, fmt.Errorf("failed to list pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			existing.Pods++
+		}
+	}
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(config.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return existing, fmt.Errorf("failed to list persistent volume claims: %v", err)
+	}
+	for _, pvc := range pvcs.Items {
+		existing.PVCs++
+		existing.Storage.Add(pvc.Spec.Resources.Requests[corev1.ResourceStorage])
+	}
+	return existing, nil
+}
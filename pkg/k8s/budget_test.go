@@ -0,0 +1,130 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func budgetNode(name string, pods int64, ephemeral string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourcePods:             *resource.NewQuantity(pods, resource.DecimalSI),
+				corev1.ResourceEphemeralStorage: resource.MustParse(ephemeral),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func budgetLimit(t *testing.T, limits []BudgetLimit, source string) BudgetLimit {
+	t.Helper()
+	for _, l := range limits {
+		if l.Source == source {
+			return l
+		}
+	}
+	t.Fatalf("limit %s not found in %+v", source, limits)
+	return BudgetLimit{}
+}
+
+func TestEstimateBudget(t *testing.T) {
+	budget, err := EstimateBudget(StatefulSetConfig{Replicas: 5000, PVCSize: "100Mi"})
+	if err != nil {
+		t.Fatalf("EstimateBudget error: %v", err)
+	}
+	if budget.Pods != 5000 || budget.PVCs != 5000 || budget.Storage.Cmp(resource.MustParse("500000Mi")) != 0 {
+		t.Fatalf("unexpected budget: %+v", budget)
+	}
+	if _, err := EstimateBudget(StatefulSetConfig{Replicas: 1, PVCSize: "lots"}); err == nil {
+		t.Fatalf("expected error for invalid pvc size")
+	}
+}
+
+func TestBudgetLimits(t *testing.T) {
+	ctx := context.Background()
+	cordoned := budgetNode("node-c", 110, "100Gi")
+	cordoned.Spec.Unschedulable = true
+	existing := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-sts-0", Namespace: "pvcbench-dev", Labels: map[string]string{"app": "pvcbench-sts"}},
+		Spec:       corev1.PodSpec{NodeName: "node-a"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	other := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+		Spec:       corev1.PodSpec{NodeName: "node-a"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	client := fake.NewSimpleClientset(
+		budgetNode("node-a", 110, "20Gi"),
+		budgetNode("node-b", 110, "20Gi"),
+		cordoned,
+		existing,
+		other,
+		&storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: "standard", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+			Provisioner: "k8s.io/minikube-hostpath",
+		},
+		&corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "small", Namespace: "pvcbench-dev"},
+			Spec:       corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("50")}},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("50")},
+				Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+			},
+		},
+	)
+	config := StatefulSetConfig{Name: "pvcbench-sts", Namespace: "pvcbench-dev", Replicas: 100, PVCSize: "100Mi"}
+
+	limits, err := BudgetLimits(ctx, client, config)
+	if err != nil {
+		t.Fatalf("BudgetLimits error: %v", err)
+	}
+	// 220 slots on the schedulable nodes, 2 taken, 1 of them by the run's
+	// own StatefulSet.
+	pods := budgetLimit(t, limits, "node allocatable")
+	if pods.Available.Value() != 219 || pods.Exceeded(0.2) {
+		t.Fatalf("unexpected pod limit: %s of %s", pods.Needed.String(), pods.Available.String())
+	}
+	storage := budgetLimit(t, limits, "node ephemeral storage")
+	if storage.Available.Cmp(resource.MustParse("40Gi")) != 0 || storage.Exceeded(0.2) {
+		t.Fatalf("unexpected storage limit: %s of %s", storage.Needed.String(), storage.Available.String())
+	}
+	quota := budgetLimit(t, limits, "ResourceQuota small")
+	if quota.Available.Value() != 41 || !quota.Exceeded(0) {
+		t.Fatalf("expected the quota to be exceeded, got %s of %s", quota.Needed.String(), quota.Available.String())
+	}
+
+	capacity := resource.MustParse("5Gi")
+	if _, err := client.StorageV1().CSIStorageCapacities("kube-system").Create(ctx, &storagev1.CSIStorageCapacity{
+		ObjectMeta:       metav1.ObjectMeta{Name: "node-a"},
+		StorageClassName: "standard",
+		Capacity:         &capacity,
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create csi storage capacity: %v", err)
+	}
+	limits, err = BudgetLimits(ctx, client, config)
+	if err != nil {
+		t.Fatalf("BudgetLimits error: %v", err)
+	}
+	if storage := budgetLimit(t, limits, "CSIStorageCapacity of standard"); !storage.Exceeded(0.2) {
+		t.Fatalf("expected 10000Mi to exceed 5Gi with margin, got %s of %s", storage.Needed.String(), storage.Available.String())
+	}
+
+	config.StaticPVs = &StaticPVOptions{Type: StaticPVHostPath}
+	config.Namespace = ""
+	limits, err = BudgetLimits(ctx, client, config)
+	if err != nil {
+		t.Fatalf("BudgetLimits error: %v", err)
+	}
+	if len(limits) != 1 || limits[0].Resource != corev1.ResourcePods {
+		t.Fatalf("expected only the pod limit for static PVs in a fresh namespace, got %+v", limits)
+	}
+}
@@ -2,33 +2,66 @@ package k8s
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func NewClient(qps float32, burst int) (*kubernetes.Clientset, error) {
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	configOverrides := &clientcmd.ConfigOverrides{}
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+// InClusterContext is the context name used for the allowlist when running
+// inside a pod with no kubeconfig.
+const InClusterContext = "in-cluster"
 
-	rawConfig, err := kubeConfig.RawConfig()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get raw kubeconfig: %v", err)
+// AllowedContextsEnv holds a comma-separated list of kubeconfig contexts or
+// API server URLs pvcbench may run against.
+const AllowedContextsEnv = "PVCBENCH_ALLOWED_CONTEXTS"
+
+type ClientOptions struct {
+	Kubeconfig string
+	Context    string
+	QPS        float32
+	Burst      int
+	// AllowedContexts lists context names or API server URLs that may be used
+	// without confirmation.
+	AllowedContexts []string
+	// Confirm is asked before using a context outside AllowedContexts. A nil
+	// Confirm refuses such contexts.
+	Confirm func(context, server string) bool
+}
+
+// DefaultAllowedContexts reads AllowedContextsEnv, falling back to minikube.
+func DefaultAllowedContexts() []string {
+	var allowed []string
+	for _, entry := range strings.Split(os.Getenv(AllowedContextsEnv), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			allowed = append(allowed, entry)
+		}
 	}
+	if len(allowed) == 0 {
+		return []string{"minikube"}
+	}
+	return allowed
+}
 
-	if rawConfig.CurrentContext != "minikube" {
-		return nil, fmt.Errorf("refusing to run: kubeconfig context is %q, expected \"minikube\"", rawConfig.CurrentContext)
+func contextAllowed(allowed []string, context, server string) bool {
+	for _, entry := range allowed {
+		if entry == context || (server != "" && strings.TrimSuffix(entry, "/") == strings.TrimSuffix(server, "/")) {
+			return true
+		}
 	}
+	return false
+}
 
-	restConfig, err := kubeConfig.ClientConfig()
+// NewClient builds a clientset from the kubeconfig, or from the pod's service
+// account when no kubeconfig is available and pvcbench runs inside a cluster.
+func NewClient(opts ClientOptions) (*kubernetes.Clientset, error) {
+	restConfig, err := NewRESTConfig(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get REST config: %v", err)
+		return nil, err
 	}
 
-	restConfig.QPS = qps
-	restConfig.Burst = burst
-
 	clientset, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %v", err)
@@ -36,3 +69,61 @@ func NewClient(qps float32, burst int) (*kubernetes.Clientset, error) {
 
 	return clientset, nil
 }
+
+// NewRESTConfig loads the client configuration and enforces the context
+// allowlist. Every client pvcbench builds goes through it.
+func NewRESTConfig(opts ClientOptions) (*rest.Config, error) {
+	restConfig, contextName, server, err := loadRESTConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !contextAllowed(opts.AllowedContexts, contextName, server) {
+		if opts.Confirm == nil || !opts.Confirm(contextName, server) {
+			return nil, fmt.Errorf("refusing to run: kubeconfig context %q (%s) is not in the allowed contexts %v", contextName, server, opts.AllowedContexts)
+		}
+	}
+
+	restConfig.QPS = opts.QPS
+	restConfig.Burst = opts.Burst
+	return restConfig, nil
+}
+
+func loadRESTConfig(opts ClientOptions) (*rest.Config, string, string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.Kubeconfig
+	configOverrides := &clientcmd.ConfigOverrides{CurrentContext: opts.Context}
+	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
+
+	rawConfig, err := kubeConfig.RawConfig()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to get raw kubeconfig: %v", err)
+	}
+
+	if opts.Kubeconfig == "" && opts.Context == "" && len(rawConfig.Contexts) == 0 {
+		restConfig, err := rest.InClusterConfig()
+		if err == nil {
+			return restConfig, InClusterContext, restConfig.Host, nil
+		}
+		if err != rest.ErrNotInCluster {
+			return nil, "", "", fmt.Errorf("failed to get in-cluster config: %v", err)
+		}
+	}
+
+	contextName := rawConfig.CurrentContext
+	if opts.Context != "" {
+		contextName = opts.Context
+	}
+	server := ""
+	if kubeContext, ok := rawConfig.Contexts[contextName]; ok {
+		if cluster, ok := rawConfig.Clusters[kubeContext.Cluster]; ok {
+			server = cluster.Server
+		}
+	}
+
+	restConfig, err := kubeConfig.ClientConfig()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to get REST config: %v", err)
+	}
+	return restConfig, contextName, server, nil
+}
@@ -0,0 +1,76 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: minikube
+clusters:
+- name: minikube
+  cluster:
+    server: https://192.168.49.2:8443
+- name: perf
+  cluster:
+    server: https://perf.example.com:6443
+contexts:
+- name: minikube
+  context:
+    cluster: minikube
+- name: perf
+  context:
+    cluster: perf
+`
+
+func writeTestKubeconfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatalf("write kubeconfig: %v", err)
+	}
+	return path
+}
+
+func TestDefaultAllowedContexts(t *testing.T) {
+	t.Setenv(AllowedContextsEnv, "")
+	if got := DefaultAllowedContexts(); len(got) != 1 || got[0] != "minikube" {
+		t.Fatalf("expected minikube default, got %v", got)
+	}
+	t.Setenv(AllowedContextsEnv, "kind-perf, https://perf.example.com:6443")
+	got := DefaultAllowedContexts()
+	if len(got) != 2 || got[0] != "kind-perf" || got[1] != "https://perf.example.com:6443" {
+		t.Fatalf("unexpected allowed contexts: %v", got)
+	}
+}
+
+func TestNewClientAllowlist(t *testing.T) {
+	kubeconfig := writeTestKubeconfig(t)
+
+	if _, err := NewClient(ClientOptions{Kubeconfig: kubeconfig, AllowedContexts: []string{"minikube"}}); err != nil {
+		t.Fatalf("expected current minikube context to be allowed: %v", err)
+	}
+
+	_, err := NewClient(ClientOptions{Kubeconfig: kubeconfig, Context: "perf", AllowedContexts: []string{"minikube"}})
+	if err == nil || !strings.Contains(err.Error(), "refusing to run") {
+		t.Fatalf("expected perf context to be refused, got %v", err)
+	}
+
+	if _, err := NewClient(ClientOptions{Kubeconfig: kubeconfig, Context: "perf", AllowedContexts: []string{"https://perf.example.com:6443/"}}); err != nil {
+		t.Fatalf("expected perf context to be allowed by server URL: %v", err)
+	}
+
+	var asked string
+	confirm := func(context, server string) bool {
+		asked = context + " " + server
+		return true
+	}
+	if _, err := NewClient(ClientOptions{Kubeconfig: kubeconfig, Context: "perf", AllowedContexts: []string{"minikube"}, Confirm: confirm}); err != nil {
+		t.Fatalf("expected confirmed context to be allowed: %v", err)
+	}
+	if asked != "perf https://perf.example.com:6443" {
+		t.Fatalf("unexpected confirmation request: %q", asked)
+	}
+}
@@ -0,0 +1,192 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"pvc-protection-bench/pkg/logging"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxDiagnosedObjects caps how many pods/PVCs are reported individually so a
+// stuck 1000-replica run does not flood the output.
+const maxDiagnosedObjects = 10
+
+type PodDiagnosis struct {
+	Name    string
+	Phase   corev1.PodPhase
+	Reason  string
+	Message string
+	Events  []string
+}
+
+type PVCDiagnosis struct {
+	Name         string
+	Phase        corev1.PersistentVolumeClaimPhase
+	StorageClass string
+	Events       []string
+}
+
+type NodeCapacity struct {
+	Name        string
+	Pods        int
+	Allocatable int64
+}
+
+type ReadinessDiagnostics struct {
+	PendingPods      []PodDiagnosis
+	PendingPodsTotal int
+	UnboundPVCs      []PVCDiagnosis
+	UnboundPVCsTotal int
+	FullNodes        []NodeCapacity
+}
+
+// Verdict names the most likely bottleneck based on what was found.
+func (d ReadinessDiagnostics) Verdict() string {
+	var causes []string
+	if len(d.FullNodes) > 0 {
+		causes = append(causes, "nodes at max-pods (cluster capacity)")
+	}
+	if d.UnboundPVCsTotal > 0 {
+		causes = append(causes, "PVCs not bound (storage provisioner)")
+	}
+	if len(causes) == 0 && d.PendingPodsTotal > 0 {
+		causes = append(causes, "pods pending (scheduling)")
+	}
+	if len(causes) == 0 {
+		return "no pending pods or unbound PVCs found"
+	}
+	return strings.Join(causes, "; ")
+}
+
+func (d ReadinessDiagnostics) Log(logger *zap.Logger) {
+	logger.Warn("readiness diagnostics",
+		logging.StringField("verdict", d.Verdict()),
+		logging.StringField("pending_pods", fmt.Sprintf("%d", d.PendingPodsTotal)),
+		logging.StringField("unbound_pvcs", fmt.Sprintf("%d", d.UnboundPVCsTotal)),
+		logging.StringField("full_nodes", fmt.Sprintf("%d", len(d.FullNodes))),
+	)
+	for _, p := range d.PendingPods {
+		logger.Warn("pod not running",
+			logging.StringField("pod", p.Name),
+			logging.StringField("phase", string(p.Phase)),
+			logging.StringField("reason", p.Reason),
+			logging.StringField("message", p.Message),
+			logging.StringField("events", strings.Join(p.Events, " | ")),
+		)
+	}
+	for _, p := range d.UnboundPVCs {
+		logger.Warn("pvc not bound",
+			logging.StringField("pvc", p.Name),
+			logging.StringField("phase", string(p.Phase)),
+			logging.StringField("storage_class", p.StorageClass),
+			logging.StringField("events", strings.Join(p.Events, " | ")),
+		)
+	}
+	for _, n := range d.FullNodes {
+		logger.Warn("node at max pods",
+			logging.StringField("node", n.Name),
+			logging.StringField("pods", fmt.Sprintf("%d/%d", n.Pods, n.Allocatable)),
+		)
+	}
+}
+
+// DiagnoseReadiness inspects the pods and PVCs matching labelSelector, and
+// every node's pod count, to explain why a StatefulSet is not becoming ready.
+func DiagnoseReadiness(ctx context.Context, client kubernetes.Interface, namespace, labelSelector string) (ReadinessDiagnostics, error) {
+	var d ReadinessDiagnostics
+
+	events, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return d, fmt.Errorf("failed to list events: %v", err)
+	}
+	eventsByObject := map[string][]string{}
+	for _, e := range events.Items {
+		key := e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name
+		eventsByObject[key] = append(eventsByObject[key], fmt.Sprintf("%s: %s", e.Reason, e.Message))
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return d, fmt.Errorf("failed to list pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			continue
+		}
+		d.PendingPodsTotal++
+		if len(d.PendingPods) >= maxDiagnosedObjects {
+			continue
+		}
+		diag := PodDiagnosis{Name: pod.Name, Phase: pod.Status.Phase, Events: eventsByObject["Pod/"+pod.Name]}
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+				diag.Reason = c.Reason
+				diag.Message = c.Message
+			}
+		}
+		d.PendingPods = append(d.PendingPods, diag)
+	}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return d, fmt.Errorf("failed to list pvcs: %v", err)
+	}
+	for _, pvc := range pvcs.Items {
+		if pvc.Status.Phase == corev1.ClaimBound {
+			continue
+		}
+		d.UnboundPVCsTotal++
+		if len(d.UnboundPVCs) >= maxDiagnosedObjects {
+			continue
+		}
+		diag := PVCDiagnosis{Name: pvc.Name, Phase: pvc.Status.Phase, Events: eventsByObject["PersistentVolumeClaim/"+pvc.Name]}
+		if pvc.Spec.StorageClassName != nil {
+			diag.StorageClass = *pvc.Spec.StorageClassName
+		}
+		d.UnboundPVCs = append(d.UnboundPVCs, diag)
+	}
+
+	d.FullNodes, err = fullNodes(ctx, client)
+	if err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+func fullNodes(ctx context.Context, client kubernetes.Interface) ([]NodeCapacity, error) {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	podsPerNode := map[string]int{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" {
+			podsPerNode[pod.Spec.NodeName]++
+		}
+	}
+
+	var full []NodeCapacity
+	for _, node := range nodes.Items {
+		allocatable := node.Status.Allocatable.Pods().Value()
+		if allocatable > 0 && int64(podsPerNode[node.Name]) >= allocatable {
+			full = append(full, NodeCapacity{Name: node.Name, Pods: podsPerNode[node.Name], Allocatable: allocatable})
+		}
+	}
+	sort.Slice(full, func(i, j int) bool {
+		return full[i].Name < full[j].Name
+	})
+	return full, nil
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiagnoseReadiness(t *testing.T) {
+	namespace := "pvcbench-test"
+	labels := map[string]string{"app": "pvcbench-sts"}
+
+	objects := []runtime.Object{
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+			Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+				corev1.ResourcePods: resource.MustParse("2"),
+			}},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-b"},
+			Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+				corev1.ResourcePods: resource.MustParse("110"),
+			}},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "ev-1", Namespace: namespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "pvcbench-sts-2"},
+			Reason:         "FailedScheduling",
+			Message:        "0/2 nodes are available: 1 Too many pods",
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "ev-2", Namespace: namespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "data-pvcbench-sts-2"},
+			Reason:         "ExternalProvisioning",
+			Message:        "waiting for a volume to be created",
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-pvcbench-sts-0", Namespace: namespace, Labels: labels},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-pvcbench-sts-2", Namespace: namespace, Labels: labels},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pvcbench-sts-%d", i), Namespace: namespace, Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: "node-a"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+	}
+	objects = append(objects, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-sts-2", Namespace: namespace, Labels: labels},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  "Unschedulable",
+				Message: "0/2 nodes are available",
+			}},
+		},
+	})
+
+	client := fake.NewSimpleClientset(objects...)
+	d, err := DiagnoseReadiness(context.Background(), client, namespace, "app=pvcbench-sts")
+	if err != nil {
+		t.Fatalf("DiagnoseReadiness error: %v", err)
+	}
+
+	if d.PendingPodsTotal != 1 || d.PendingPods[0].Reason != "Unschedulable" || len(d.PendingPods[0].Events) != 1 {
+		t.Fatalf("unexpected pending pods: %+v", d.PendingPods)
+	}
+	if d.UnboundPVCsTotal != 1 || d.UnboundPVCs[0].Name != "data-pvcbench-sts-2" || len(d.UnboundPVCs[0].Events) != 1 {
+		t.Fatalf("unexpected unbound pvcs: %+v", d.UnboundPVCs)
+	}
+	if len(d.FullNodes) != 1 || d.FullNodes[0].Name != "node-a" || d.FullNodes[0].Pods != 2 {
+		t.Fatalf("unexpected full nodes: %+v", d.FullNodes)
+	}
+	if verdict := d.Verdict(); !strings.Contains(verdict, "max-pods") || !strings.Contains(verdict, "storage provisioner") {
+		t.Fatalf("unexpected verdict: %s", verdict)
+	}
+}
+
+func TestReadinessVerdictHealthy(t *testing.T) {
+	if verdict := (ReadinessDiagnostics{}).Verdict(); verdict != "no pending pods or unbound PVCs found" {
+		t.Fatalf("unexpected verdict: %s", verdict)
+	}
+}
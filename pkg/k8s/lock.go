@@ -0,0 +1,252 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"pvc-protection-bench/pkg/logging"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	DefaultLockNamespace = "default"
+	DefaultLockName      = "pvcbench-run-lock"
+	lockRetryInterval    = 5 * time.Second
+)
+
+type LockOptions struct {
+	Namespace     string
+	Name          string
+	Identity      string
+	LeaseDuration time.Duration
+	// Wait keeps retrying while another run holds the lock instead of
+	// failing with a LockHeldError.
+	Wait bool
+	// RetryInterval is the wait between attempts; zero uses 5s.
+	RetryInterval time.Duration
+}
+
+type LockHeldError struct {
+	Namespace string
+	Name      string
+	Holder    string
+	RenewedAt time.Time
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("another run holds lease %s/%s: %s (renewed %s ago); use --wait-for-lock to wait for it",
+		e.Namespace, e.Name, e.Holder, time.Since(e.RenewedAt).Round(time.Second))
+}
+
+// RunLock is a held Lease that is renewed in the background until Release.
+type RunLock struct {
+	client  kubernetes.Interface
+	opts    LockOptions
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	lost    chan struct{}
+	lostErr error
+}
+
+// LockIdentity names this process as user@host/pid.
+func LockIdentity() string {
+	return fmt.Sprintf("%s/%d", Operator(), os.Getpid())
+}
+
+// AcquireLock takes the Lease described by opts, waiting for the current
+// holder when opts.Wait is set. Expired leases are taken over.
+func AcquireLock(ctx context.Context, client kubernetes.Interface, opts LockOptions) (*RunLock, error) {
+	logger := logging.GetLogger().With(
+		logging.StringField("lease", opts.Namespace+"/"+opts.Name),
+		logging.StringField("identity", opts.Identity),
+	)
+	retryInterval := opts.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = lockRetryInterval
+	}
+	for {
+		err := tryAcquireLease(ctx, client, opts)
+		if err == nil {
+			break
+		}
+		heldErr, ok := err.(*LockHeldError)
+		if !ok && !apierrors.IsConflict(err) && !apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed to acquire lease %s/%s: %v", opts.Namespace, opts.Name, err)
+		}
+		if ok && !opts.Wait {
+			return nil, heldErr
+		}
+		if ok {
+			logger.Info("waiting for run lock", logging.StringField("holder", heldErr.Holder))
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+	logger.Info("acquired run lock")
+
+	renewCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	lock := &RunLock{client: client, opts: opts, cancel: cancel, lost: make(chan struct{})}
+	lock.wg.Add(1)
+	go lock.renew(renewCtx)
+	return lock, nil
+}
+
+func tryAcquireLease(ctx context.Context, client kubernetes.Interface, opts LockOptions) error {
+	now := metav1.NewMicroTime(time.Now())
+	durationSeconds := int32(opts.LeaseDuration / time.Second)
+	leases := client.CoordinationV1().Leases(opts.Namespace)
+
+	lease, err := leases.Get(ctx, opts.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: opts.Name, Namespace: opts.Namespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &opts.Identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if holder := leaseHolder(lease); holder != "" && holder != opts.Identity && !leaseExpired(lease) {
+		heldErr := &LockHeldError{Namespace: opts.Namespace, Name: opts.Name, Holder: holder}
+		if lease.Spec.RenewTime != nil {
+			heldErr.RenewedAt = lease.Spec.RenewTime.Time
+		}
+		return heldErr
+	}
+
+	lease.Spec.HolderIdentity = &opts.Identity
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expiry)
+}
+
+func (l *RunLock) Holder() string {
+	return l.opts.Identity
+}
+
+// Lost is closed when the lock stops being held before Release: another run
+// took the lease over, or it was not renewed for a whole LeaseDuration, after
+// which another run may take it.
+func (l *RunLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns why the lock was lost, or nil while it is held.
+func (l *RunLock) Err() error {
+	select {
+	case <-l.lost:
+		return l.lostErr
+	default:
+		return nil
+	}
+}
+
+func (l *RunLock) renew(ctx context.Context) {
+	defer l.wg.Done()
+	logger := logging.GetLogger().With(logging.StringField("lease", l.opts.Namespace+"/"+l.opts.Name))
+	ticker := time.NewTicker(l.opts.LeaseDuration / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := l.renewOnce(ctx)
+		if err == nil {
+			renewed = time.Now()
+			continue
+		}
+		if heldErr, ok := err.(*LockHeldError); ok {
+			l.lostErr = fmt.Errorf("run lock %s/%s was taken over by %q", l.opts.Namespace, l.opts.Name, heldErr.Holder)
+		} else if time.Since(renewed) >= l.opts.LeaseDuration {
+			l.lostErr = fmt.Errorf("run lock %s/%s not renewed within its %s lease: %v", l.opts.Namespace, l.opts.Name, l.opts.LeaseDuration, err)
+		} else {
+			logger.Error("failed to renew run lock", logging.ErrorField(err))
+			continue
+		}
+		logger.Error("lost run lock", logging.ErrorField(l.lostErr))
+		close(l.lost)
+		return
+	}
+}
+
+// renewOnce bumps the renew time, or returns a LockHeldError when another
+// run holds the lease.
+func (l *RunLock) renewOnce(ctx context.Context) error {
+	leases := l.client.CoordinationV1().Leases(l.opts.Namespace)
+	lease, err := leases.Get(ctx, l.opts.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if holder := leaseHolder(lease); holder != l.opts.Identity {
+		heldErr := &LockHeldError{Namespace: l.opts.Namespace, Name: l.opts.Name, Holder: holder}
+		if lease.Spec.RenewTime != nil {
+			heldErr.RenewedAt = lease.Spec.RenewTime.Time
+		}
+		return heldErr
+	}
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// Release stops renewal and clears the holder so the next run can start
+// without waiting for the lease to expire.
+func (l *RunLock) Release() error {
+	l.cancel()
+	l.wg.Wait()
+
+	ctx := context.Background()
+	leases := l.client.CoordinationV1().Leases(l.opts.Namespace)
+	lease, err := leases.Get(ctx, l.opts.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to release lease %s/%s: %v", l.opts.Namespace, l.opts.Name, err)
+	}
+	if leaseHolder(lease) != l.opts.Identity {
+		return nil
+	}
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.RenewTime = nil
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to release lease %s/%s: %v", l.opts.Namespace, l.opts.Name, err)
+	}
+	logging.GetLogger().Info("released run lock", logging.StringField("lease", l.opts.Namespace+"/"+l.opts.Name))
+	return nil
+}
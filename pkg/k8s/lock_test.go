@@ -0,0 +1,163 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testLockOptions(identity string) LockOptions {
+	return LockOptions{
+		Namespace:     "default",
+		Name:          DefaultLockName,
+		Identity:      identity,
+		LeaseDuration: 30 * time.Second,
+		RetryInterval: time.Millisecond,
+	}
+}
+
+func TestAcquireLockRefusesWhileHeld(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	lock, err := AcquireLock(ctx, client, testLockOptions("alice"))
+	if err != nil {
+		t.Fatalf("AcquireLock error: %v", err)
+	}
+
+	_, err = AcquireLock(ctx, client, testLockOptions("bob"))
+	var heldErr *LockHeldError
+	if !errors.As(err, &heldErr) || heldErr.Holder != "alice" {
+		t.Fatalf("expected lock held by alice, got %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release error: %v", err)
+	}
+	next, err := AcquireLock(ctx, client, testLockOptions("bob"))
+	if err != nil {
+		t.Fatalf("expected released lock to be acquired: %v", err)
+	}
+	if next.Holder() != "bob" {
+		t.Fatalf("expected bob to hold the lock, got %s", next.Holder())
+	}
+	_ = next.Release()
+}
+
+func TestAcquireLockTakesOverExpiredLease(t *testing.T) {
+	holder := "stale"
+	duration := int32(30)
+	renewed := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+	client := fake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: DefaultLockName, Namespace: "default"},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renewed,
+		},
+	})
+
+	lock, err := AcquireLock(context.Background(), client, testLockOptions("alice"))
+	if err != nil {
+		t.Fatalf("expected expired lease to be taken over: %v", err)
+	}
+	_ = lock.Release()
+}
+
+func TestAcquireLockWaitsForHolder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	first, err := AcquireLock(ctx, client, testLockOptions("alice"))
+	if err != nil {
+		t.Fatalf("AcquireLock error: %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = first.Release()
+	}()
+
+	opts := testLockOptions("bob")
+	opts.Wait = true
+	second, err := AcquireLock(ctx, client, opts)
+	if err != nil {
+		t.Fatalf("expected waiting acquire to succeed: %v", err)
+	}
+	_ = second.Release()
+}
+
+func TestRunLockLostOnTakeover(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+	opts := testLockOptions("alice")
+	opts.LeaseDuration = 30 * time.Millisecond
+
+	lock, err := AcquireLock(ctx, client, opts)
+	if err != nil {
+		t.Fatalf("AcquireLock error: %v", err)
+	}
+	defer lock.Release()
+	if lock.Err() != nil {
+		t.Fatalf("expected a fresh lock to be held, got %v", lock.Err())
+	}
+
+	lease, err := client.CoordinationV1().Leases("default").Get(ctx, DefaultLockName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get lease: %v", err)
+	}
+	bob := "bob"
+	lease.Spec.HolderIdentity = &bob
+	if _, err := client.CoordinationV1().Leases("default").Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("take over lease: %v", err)
+	}
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the takeover to be noticed")
+	}
+	if err := lock.Err(); err == nil || !strings.Contains(err.Error(), `taken over by "bob"`) {
+		t.Fatalf("expected a takeover error, got %v", err)
+	}
+}
+
+func TestRunLockLostWhenRenewalFails(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	opts := testLockOptions("alice")
+	opts.LeaseDuration = 30 * time.Millisecond
+
+	var down atomic.Bool
+	client.PrependReactor("get", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if down.Load() {
+			return true, nil, apierrors.NewServiceUnavailable("down")
+		}
+		return false, nil, nil
+	})
+
+	lock, err := AcquireLock(context.Background(), client, opts)
+	if err != nil {
+		t.Fatalf("AcquireLock error: %v", err)
+	}
+	defer lock.Release()
+	down.Store(true)
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected failed renewals to lose the lock")
+	}
+	if err := lock.Err(); err == nil || !strings.Contains(err.Error(), "not renewed") {
+		t.Fatalf("expected a renewal error, got %v", err)
+	}
+}
@@ -10,7 +10,11 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-func EnsureNamespace(ctx context.Context, client kubernetes.Interface, name string) error {
+// NamespacePrefix starts the name of every run namespace. Run namespaces are
+// selected by their labels, see RunMetadata.
+const NamespacePrefix = "pvcbench-"
+
+func EnsureNamespace(ctx context.Context, client kubernetes.Interface, name string, meta RunMetadata) error {
 	_, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 	if err == nil {
 		return nil
@@ -21,10 +25,12 @@ func EnsureNamespace(ctx context.Context, client kubernetes.Interface, name stri
 
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
+			Name:        name,
+			Labels:      meta.Labels(),
+			Annotations: meta.Annotations(),
 		},
 	}
-	_, err = client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	_, err = client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{FieldManager: FieldManager})
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create namespace %s: %v", name, err)
 	}
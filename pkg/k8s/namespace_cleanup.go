@@ -2,54 +2,204 @@ package k8s
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"pvc-protection-bench/pkg/logging"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
-func WaitForNamespaceDeleted(ctx context.Context, client kubernetes.Interface, name string) error {
-	return wait.PollImmediate(1*time.Second, 10*time.Minute, func() (bool, error) {
-		_, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+func WaitForNamespaceDeleted(ctx context.Context, client kubernetes.Interface, name string, timeout time.Duration) error {
+	return pollUntil(ctx, "cleanup", timeout, func(ctx context.Context) (bool, string, error) {
+		ns, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 		if err == nil {
-			return false, nil
+			return false, fmt.Sprintf("namespace %s still %s", name, ns.Status.Phase), nil
 		}
 		if apierrors.IsNotFound(err) {
-			return true, nil
+			return true, "", nil
 		}
-		return false, err
+		return false, "", err
 	})
 }
 
-func ForceDeleteNamespace(ctx context.Context, client kubernetes.Interface, name string) error {
-	logger := logging.GetLogger()
+type ForceDeleteOptions struct {
+	// Wait is how long the namespace controller gets to delete the content
+	// before finalizers are removed, and again how long the unblocked objects
+	// get to go before the namespace is finalized regardless. It must be set:
+	// finalizing a namespace with content left orphans that content in etcd.
+	Wait time.Duration
+	// DeletePVs also deletes PersistentVolumes whose claimRef points into the
+	// namespace, which would otherwise be left Released.
+	DeletePVs bool
+}
 
-	pvcs, err := client.CoreV1().PersistentVolumeClaims(name).List(ctx, metav1.ListOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		return err
+// ForceDeleteSummary lists everything ForceDeleteNamespace removed by force.
+type ForceDeleteSummary struct {
+	Namespace string
+	// Unblocked holds the objects whose finalizers were stripped.
+	Unblocked          []RemainingObject
+	NamespaceFinalized bool
+	// Orphaned holds the objects still in the namespace when it was
+	// finalized; they stay in etcd until a namespace of the same name is
+	// created and deleted again.
+	Orphaned   []RemainingObject
+	DeletedPVs []string
+}
+
+// ForceDeleteNamespace waits up to opts.Wait for a namespace that is already
+// being deleted to go. If it does not, it strips the finalizers from the
+// objects left Terminating in it, waits for them to be removed and only then
+// clears the namespace's own finalizers through the finalize subresource.
+// Objects that were never marked for deletion keep their finalizers. The
+// summary is returned even on error so that callers can report what was
+// already removed.
+func ForceDeleteNamespace(ctx context.Context, client kubernetes.Interface, dyn dynamic.Interface, name string, opts ForceDeleteOptions) (ForceDeleteSummary, error) {
+	logger := logging.GetLogger().With(logging.StringField("namespace", name))
+	summary := ForceDeleteSummary{Namespace: name}
+
+	var timeoutErr *PhaseTimeoutError
+	err := WaitForNamespaceDeleted(ctx, client, name, opts.Wait)
+	if err == nil {
+		return summary, nil
+	}
+	if !errors.As(err, &timeoutErr) {
+		return summary, err
+	}
+	logger.Warn("namespace not deleted in time, removing finalizers of terminating objects", logging.ErrorField(err))
+
+	resources, err := namespacedResources(client.Discovery(), "list", "patch")
+	if err != nil {
+		if len(resources) == 0 {
+			return summary, err
+		}
+		logger.Warn("partial discovery failure, some objects may keep their finalizers", logging.ErrorField(err))
 	}
-	for _, pvc := range pvcs.Items {
-		if len(pvc.Finalizers) == 0 {
+	remaining, err := listRemaining(ctx, dyn, resources, name)
+	if err != nil {
+		return summary, err
+	}
+	patch := []byte(`{"metadata":{"finalizers":null}}`)
+	for _, o := range remaining {
+		if !o.object.Terminating || len(o.object.Finalizers) == 0 {
 			continue
 		}
-		patch := []byte(`{"metadata":{"finalizers":[]}}`)
-		_, err := client.CoreV1().PersistentVolumeClaims(name).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		_, err := dyn.Resource(o.gvr).Namespace(name).Patch(ctx, o.object.Name, types.MergePatchType, patch, metav1.PatchOptions{})
 		if err != nil && !apierrors.IsNotFound(err) {
-			logger.Error("failed to remove pvc finalizers", logging.StringField("pvc", pvc.Name), logging.ErrorField(err))
-			return err
+			logger.Error("failed to remove finalizers", logging.StringField("resource", o.object.Resource), logging.StringField("name", o.object.Name), logging.ErrorField(err))
+			return summary, fmt.Errorf("failed to remove finalizers from %s %s: %v", o.object.Resource, o.object.Name, err)
+		}
+		summary.Unblocked = append(summary.Unblocked, o.object)
+	}
+
+	// Finalize only once the namespace is empty, or when what is left does
+	// not go within another wait.
+	err = pollUntil(ctx, "cleanup", opts.Wait, func(ctx context.Context) (bool, string, error) {
+		var err error
+		remaining, err = listRemaining(ctx, dyn, resources, name)
+		if err != nil {
+			return false, "", err
+		}
+		return len(remaining) == 0, fmt.Sprintf("%d objects remaining in namespace %s", len(remaining), name), nil
+	})
+	if err != nil && !errors.As(err, &timeoutErr) {
+		return summary, err
+	}
+
+	ns, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return summary, err
+	case len(ns.Spec.Finalizers) > 0:
+		if len(remaining) > 0 {
+			logger.Warn("finalizing namespace with objects remaining", logging.StringField("remaining", fmt.Sprintf("%d", len(remaining))))
+		}
+		ns.Spec.Finalizers = nil
+		if _, err := client.CoreV1().Namespaces().Finalize(ctx, ns, metav1.UpdateOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return summary, fmt.Errorf("failed to finalize namespace %s: %v", name, err)
+		}
+		summary.NamespaceFinalized = true
+		for _, o := range remaining {
+			summary.Orphaned = append(summary.Orphaned, o.object)
+		}
+	}
+
+	if opts.DeletePVs {
+		pvs, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return summary, fmt.Errorf("failed to list persistent volumes: %v", err)
+		}
+		for _, pv := range pvs.Items {
+			if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.Namespace != name {
+				continue
+			}
+			if err := DeletePersistentVolume(ctx, client, &pv); err != nil {
+				return summary, err
+			}
+			summary.DeletedPVs = append(summary.DeletedPVs, pv.Name)
 		}
 	}
 
-	nsPatch := []byte(`{"spec":{"finalizers":[]}}`)
-	_, err = client.CoreV1().Namespaces().Patch(ctx, name, types.MergePatchType, nsPatch, metav1.PatchOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		return err
+	return summary, WaitForNamespaceDeleted(ctx, client, name, opts.Wait)
+}
+
+type remainingResource struct {
+	gvr    schema.GroupVersionResource
+	object RemainingObject
+}
+
+func listRemaining(ctx context.Context, dyn dynamic.Interface, resources []schema.GroupVersionResource, name string) ([]remainingResource, error) {
+	var remaining []remainingResource
+	for _, gvr := range resources {
+		list, err := dyn.Resource(gvr).Namespace(name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to list %s in %s: %v", resourceName(gvr), name, err)
+		}
+		for _, item := range list.Items {
+			remaining = append(remaining, remainingResource{gvr: gvr, object: RemainingObject{
+				Resource:    resourceName(gvr),
+				Name:        item.GetName(),
+				Finalizers:  item.GetFinalizers(),
+				Terminating: item.GetDeletionTimestamp() != nil,
+			}})
+		}
 	}
+	return remaining, nil
+}
+
+type NamespaceResources struct {
+	StatefulSets int
+	Pods         int
+	PVCs         int
+}
 
-	return WaitForNamespaceDeleted(ctx, client, name)
+// CountNamespaceResources counts what deleting the namespace would remove.
+func CountNamespaceResources(ctx context.Context, client kubernetes.Interface, name string) (NamespaceResources, error) {
+	var counts NamespaceResources
+	sts, err := client.AppsV1().StatefulSets(name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return counts, fmt.Errorf("failed to list statefulsets in %s: %v", name, err)
+	}
+	counts.StatefulSets = len(sts.Items)
+	pods, err := client.CoreV1().Pods(name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return counts, fmt.Errorf("failed to list pods in %s: %v", name, err)
+	}
+	counts.Pods = len(pods.Items)
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(name).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return counts, fmt.Errorf("failed to list pvcs in %s: %v", name, err)
+	}
+	counts.PVCs = len(pvcs.Items)
+	return counts, nil
 }
@@ -11,61 +11,114 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
 	k8stesting "k8s.io/client-go/testing"
 )
 
-func TestForceDeleteNamespacePatchesFinalizers(t *testing.T) {
-	client := fake.NewSimpleClientset()
+func TestForceDeleteNamespace(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	ns := &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:       "pvcbench-test",
-			Finalizers: []string{"kubernetes"},
-		},
-	}
-	pvc := &corev1.PersistentVolumeClaim{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:       "pvc-1",
-			Namespace:  ns.Name,
-			Finalizers: []string{"kubernetes.io/pvc-protection"},
-		},
+	ns, pvc, pod := terminatingNamespaceObjects("pvcbench-test")
+	pod.Finalizers = []string{"example.com/hold"}
+	pv := func(name, claimNamespace string) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PersistentVolumeSpec{ClaimRef: &corev1.ObjectReference{Namespace: claimNamespace, Name: "data"}},
+		}
 	}
+	client := fake.NewSimpleClientset(ns, pv("pv-1", ns.Name), pv("pv-other", "other"))
+	client.Resources = testAPIResources
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pvc, pod)
+
+	var finalized atomic.Bool
+	client.PrependReactor("create", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "finalize" {
+			return false, nil, nil
+		}
+		obj := action.(k8stesting.CreateAction).GetObject().(*corev1.Namespace)
+		if len(obj.Spec.Finalizers) != 0 {
+			t.Errorf("expected finalize to clear spec finalizers, got %v", obj.Spec.Finalizers)
+		}
+		finalized.Store(true)
+		return true, obj, nil
+	})
+	client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if finalized.Load() {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, ns.Name)
+		}
+		return false, nil, nil
+	})
 
-	_, err := client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	summary, err := ForceDeleteNamespace(ctx, client, dyn, ns.Name, ForceDeleteOptions{Wait: 50 * time.Millisecond, DeletePVs: true})
 	if err != nil {
-		t.Fatalf("create namespace: %v", err)
+		t.Fatalf("ForceDeleteNamespace error: %v", err)
 	}
-	_, err = client.CoreV1().PersistentVolumeClaims(ns.Name).Create(ctx, pvc, metav1.CreateOptions{})
-	if err != nil {
-		t.Fatalf("create pvc: %v", err)
+	if !summary.NamespaceFinalized {
+		t.Fatalf("expected namespace to be finalized")
+	}
+	if len(summary.Unblocked) != 1 || summary.Unblocked[0].Resource != "persistentvolumeclaims" {
+		t.Fatalf("expected only the terminating pvc to be unblocked, got %+v", summary.Unblocked)
+	}
+	// The fake never removes objects, so both are still there when the
+	// namespace is finalized after the second wait.
+	if len(summary.Orphaned) != 2 {
+		t.Fatalf("expected the pvc and pod to be reported orphaned, got %+v", summary.Orphaned)
+	}
+	for gvr, finalizers := range map[schema.GroupVersionResource]int{
+		corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"): 0,
+		corev1.SchemeGroupVersion.WithResource("pods"):                   1,
+	} {
+		list, err := dyn.Resource(gvr).Namespace(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatalf("list %s: %v", gvr.Resource, err)
+		}
+		for _, item := range list.Items {
+			if len(item.GetFinalizers()) != finalizers {
+				t.Fatalf("expected %s %s to have %d finalizers, got %v", gvr.Resource, item.GetName(), finalizers, item.GetFinalizers())
+			}
+		}
 	}
+	if len(summary.DeletedPVs) != 1 || summary.DeletedPVs[0] != "pv-1" {
+		t.Fatalf("expected only pv-1 to be deleted, got %v", summary.DeletedPVs)
+	}
+	if _, err := client.CoreV1().PersistentVolumes().Get(ctx, "pv-other", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected pv-other to be kept: %v", err)
+	}
+}
 
-	var pvcPatchCount int32
-	var nsPatchCount int32
+func TestForceDeleteNamespaceWaitsForNormalDeletion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 
-	client.PrependReactor("patch", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
-		atomic.AddInt32(&pvcPatchCount, 1)
-		return true, pvc, nil
-	})
-	client.PrependReactor("patch", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
-		atomic.AddInt32(&nsPatchCount, 1)
-		return true, ns, nil
-	})
+	ns, pvc, pod := terminatingNamespaceObjects("pvcbench-test")
+	client := fake.NewSimpleClientset(ns)
+	client.Resources = testAPIResources
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pvc, pod)
+
+	var gets atomic.Int32
 	client.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
-		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "namespaces"}, ns.Name)
+		if gets.Add(1) > 1 {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, ns.Name)
+		}
+		return false, nil, nil
 	})
 
-	if err := ForceDeleteNamespace(ctx, client, ns.Name); err != nil {
+	summary, err := ForceDeleteNamespace(ctx, client, dyn, ns.Name, ForceDeleteOptions{Wait: 5 * time.Second, DeletePVs: true})
+	if err != nil {
 		t.Fatalf("ForceDeleteNamespace error: %v", err)
 	}
-	if pvcPatchCount == 0 {
-		t.Fatalf("expected pvc patch to be called")
+	if summary.NamespaceFinalized || len(summary.Unblocked) != 0 || len(summary.DeletedPVs) != 0 {
+		t.Fatalf("expected nothing to be forced when the namespace goes by itself, got %+v", summary)
+	}
+	got, err := dyn.Resource(corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims")).Namespace(ns.Name).Get(ctx, pvc.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pvc: %v", err)
 	}
-	if nsPatchCount == 0 {
-		t.Fatalf("expected namespace patch to be called")
+	if len(got.GetFinalizers()) != 1 {
+		t.Fatalf("expected the pvc to keep its finalizer, got %v", got.GetFinalizers())
 	}
 }
 
@@ -80,7 +133,7 @@ func TestWaitForNamespaceDeleted(t *testing.T) {
 		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "namespaces"}, name)
 	})
 
-	if err := WaitForNamespaceDeleted(ctx, client, name); err != nil {
+	if err := WaitForNamespaceDeleted(ctx, client, name, time.Second); err != nil {
 		t.Fatalf("WaitForNamespaceDeleted error: %v", err)
 	}
 }
@@ -0,0 +1,174 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"pvc-protection-bench/pkg/logging"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+type RemainingObject struct {
+	// Resource is the plural resource name, qualified with its group for
+	// non-core types, e.g. "persistentvolumeclaims" or "volumesnapshots.snapshot.storage.k8s.io".
+	Resource    string
+	Name        string
+	Finalizers  []string
+	Terminating bool
+}
+
+type NamespaceTerminationReport struct {
+	Name       string
+	Phase      corev1.NamespacePhase
+	Conditions []corev1.NamespaceCondition
+	Finalizers []corev1.FinalizerName
+	Remaining  []RemainingObject
+	// ListErrors holds resources that could not be listed, so a short
+	// Remaining list is not mistaken for a complete one.
+	ListErrors []string
+}
+
+// Counts returns the number of remaining objects per resource.
+func (r NamespaceTerminationReport) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, o := range r.Remaining {
+		counts[o.Resource]++
+	}
+	return counts
+}
+
+func (r NamespaceTerminationReport) Log(logger *zap.Logger) {
+	var counts []string
+	for resource, n := range r.Counts() {
+		counts = append(counts, fmt.Sprintf("%s=%d", resource, n))
+	}
+	sort.Strings(counts)
+	finalizers := make([]string, 0, len(r.Finalizers))
+	for _, f := range r.Finalizers {
+		finalizers = append(finalizers, string(f))
+	}
+	logger.Warn("namespace stuck terminating",
+		logging.StringField("namespace", r.Name),
+		logging.StringField("phase", string(r.Phase)),
+		logging.StringField("finalizers", strings.Join(finalizers, ",")),
+		logging.StringField("remaining", strings.Join(counts, ",")),
+	)
+	for _, c := range r.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		logger.Warn("namespace condition",
+			logging.StringField("namespace", r.Name),
+			logging.StringField("type", string(c.Type)),
+			logging.StringField("reason", c.Reason),
+			logging.StringField("message", c.Message),
+		)
+	}
+	logged := 0
+	for _, o := range r.Remaining {
+		if len(o.Finalizers) == 0 {
+			continue
+		}
+		if logged >= maxDiagnosedObjects {
+			break
+		}
+		logger.Warn("object blocked by finalizers",
+			logging.StringField("namespace", r.Name),
+			logging.StringField("resource", o.Resource),
+			logging.StringField("name", o.Name),
+			logging.StringField("finalizers", strings.Join(o.Finalizers, ",")),
+			logging.StringField("terminating", fmt.Sprintf("%t", o.Terminating)),
+		)
+		logged++
+	}
+	for _, e := range r.ListErrors {
+		logger.Warn("could not list remaining objects", logging.StringField("namespace", r.Name), logging.StringField("error", e))
+	}
+}
+
+// ignoredResources are left out of namespace contents: events are recorded
+// about the deletion itself, carry no finalizers and never hold a namespace
+// in Terminating, so counting them would report a drained namespace as busy.
+var ignoredResources = map[string]bool{
+	"events":               true,
+	"events.events.k8s.io": true,
+}
+
+// namespacedResources returns every namespaced resource type that supports
+// the given verbs, at the version preferred by the server, except the
+// ignoredResources. Partial discovery failures are tolerated and returned
+// alongside the resources found.
+func namespacedResources(disco discovery.DiscoveryInterface, verbs ...string) ([]schema.GroupVersionResource, error) {
+	lists, err := discovery.ServerPreferredNamespacedResources(disco)
+	if err != nil && len(lists) == 0 {
+		return nil, fmt.Errorf("failed to discover namespaced resources: %v", err)
+	}
+	lists = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: verbs}, lists)
+	var resources []schema.GroupVersionResource
+	for _, list := range lists {
+		gv, parseErr := schema.ParseGroupVersion(list.GroupVersion)
+		if parseErr != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			gvr := gv.WithResource(r.Name)
+			if strings.Contains(r.Name, "/") || ignoredResources[resourceName(gvr)] {
+				continue
+			}
+			resources = append(resources, gvr)
+		}
+	}
+	return resources, err
+}
+
+func resourceName(gvr schema.GroupVersionResource) string {
+	if gvr.Group == "" {
+		return gvr.Resource
+	}
+	return gvr.Resource + "." + gvr.Group
+}
+
+// DiagnoseNamespaceTermination explains why a namespace is not gone: its
+// status conditions and spec finalizers, and every object of every namespaced
+// type that still exists in it together with the finalizers holding it.
+func DiagnoseNamespaceTermination(ctx context.Context, client kubernetes.Interface, dyn dynamic.Interface, name string) (NamespaceTerminationReport, error) {
+	report := NamespaceTerminationReport{Name: name}
+
+	ns, err := client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return report, fmt.Errorf("failed to get namespace %s: %v", name, err)
+	}
+	report.Phase = ns.Status.Phase
+	report.Conditions = ns.Status.Conditions
+	report.Finalizers = ns.Spec.Finalizers
+
+	resources, err := namespacedResources(client.Discovery(), "list")
+	if err != nil {
+		report.ListErrors = append(report.ListErrors, err.Error())
+	}
+	for _, gvr := range resources {
+		list, err := dyn.Resource(gvr).Namespace(name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			report.ListErrors = append(report.ListErrors, fmt.Sprintf("%s: %v", resourceName(gvr), err))
+			continue
+		}
+		for _, item := range list.Items {
+			report.Remaining = append(report.Remaining, RemainingObject{
+				Resource:    resourceName(gvr),
+				Name:        item.GetName(),
+				Finalizers:  item.GetFinalizers(),
+				Terminating: item.GetDeletionTimestamp() != nil,
+			})
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var testAPIResources = []*metav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "delete", "patch"}},
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "delete", "patch"}},
+			{Name: "pods/status", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get", "patch"}},
+			{Name: "events", Kind: "Event", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "delete", "patch"}},
+			{Name: "nodes", Kind: "Node", Namespaced: false, Verbs: metav1.Verbs{"get", "list"}},
+		},
+	},
+	{
+		GroupVersion: "events.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "events", Kind: "Event", Namespaced: true, Verbs: metav1.Verbs{"get", "list", "delete", "patch"}},
+		},
+	},
+}
+
+func terminatingNamespaceObjects(namespace string) (*corev1.Namespace, *corev1.PersistentVolumeClaim, *corev1.Pod) {
+	now := metav1.Now()
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace, DeletionTimestamp: &now, Finalizers: []string{"kubernetes"}},
+		Spec:       corev1.NamespaceSpec{Finalizers: []corev1.FinalizerName{corev1.FinalizerKubernetes}},
+		Status: corev1.NamespaceStatus{
+			Phase: corev1.NamespaceTerminating,
+			Conditions: []corev1.NamespaceCondition{
+				{Type: corev1.NamespaceContentRemaining, Status: corev1.ConditionTrue, Reason: "SomeResourcesRemain", Message: "Some resources are remaining: persistentvolumeclaims. has 1 resource instances"},
+				{Type: corev1.NamespaceFinalizersRemaining, Status: corev1.ConditionTrue, Reason: "SomeFinalizersRemain", Message: "Some content in the namespace has finalizers remaining: kubernetes.io/pvc-protection in 1 resource instances"},
+			},
+		},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-pvcbench-sts-0", Namespace: namespace, DeletionTimestamp: &now, Finalizers: []string{"kubernetes.io/pvc-protection"}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-sts-0", Namespace: namespace},
+	}
+	return ns, pvc, pod
+}
+
+func TestDiagnoseNamespaceTermination(t *testing.T) {
+	ns, pvc, pod := terminatingNamespaceObjects("pvcbench-stuck")
+	client := fake.NewSimpleClientset(ns)
+	client.Resources = testAPIResources
+	event := &corev1.Event{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-sts-0.1", Namespace: "pvcbench-stuck"}}
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, pvc, pod, event)
+
+	report, err := DiagnoseNamespaceTermination(context.Background(), client, dyn, "pvcbench-stuck")
+	if err != nil {
+		t.Fatalf("DiagnoseNamespaceTermination error: %v", err)
+	}
+	if report.Phase != corev1.NamespaceTerminating || len(report.Conditions) != 2 {
+		t.Fatalf("unexpected namespace status: %s %v", report.Phase, report.Conditions)
+	}
+	if len(report.Finalizers) != 1 || report.Finalizers[0] != corev1.FinalizerKubernetes {
+		t.Fatalf("unexpected namespace finalizers: %v", report.Finalizers)
+	}
+	counts := report.Counts()
+	if counts["persistentvolumeclaims"] != 1 || counts["pods"] != 1 || len(counts) != 2 {
+		t.Fatalf("unexpected remaining counts: %v", counts)
+	}
+	for _, o := range report.Remaining {
+		if o.Resource == "persistentvolumeclaims" && (!o.Terminating || len(o.Finalizers) != 1) {
+			t.Fatalf("expected pvc to be terminating with a finalizer: %+v", o)
+		}
+	}
+	if len(report.ListErrors) != 0 {
+		t.Fatalf("unexpected list errors: %v", report.ListErrors)
+	}
+}
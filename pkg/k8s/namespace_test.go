@@ -14,7 +14,7 @@ func TestEnsureNamespaceCreatesWhenMissing(t *testing.T) {
 	ctx := context.Background()
 	name := "pvcbench-test"
 
-	if err := EnsureNamespace(ctx, client, name); err != nil {
+	if err := EnsureNamespace(ctx, client, name, RunMetadata{}); err != nil {
 		t.Fatalf("EnsureNamespace error: %v", err)
 	}
 
@@ -47,7 +47,34 @@ func TestEnsureNamespaceNoopWhenExists(t *testing.T) {
 		t.Fatalf("create namespace: %v", err)
 	}
 
-	if err := EnsureNamespace(ctx, client, "pvcbench-test"); err != nil {
+	if err := EnsureNamespace(ctx, client, "pvcbench-test", RunMetadata{}); err != nil {
 		t.Fatalf("EnsureNamespace error: %v", err)
 	}
 }
+
+func TestEnsureNamespaceLabelsRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+	meta := RunMetadata{
+		RunID:       "0b7e3c2a-1111-2222-3333-444455556666",
+		Scenario:    "staggered",
+		Params:      map[string]string{"replicas": "10"},
+		ToolVersion: "v1.2.3",
+		Operator:    "alice@laptop",
+	}
+
+	if err := EnsureNamespace(ctx, client, RunNamespace(meta.RunID), meta); err != nil {
+		t.Fatalf("EnsureNamespace error: %v", err)
+	}
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: LabelRunID + "=" + meta.RunID})
+	if err != nil || len(namespaces.Items) != 1 {
+		t.Fatalf("expected namespace to be selectable by run id: %v", err)
+	}
+	ns := namespaces.Items[0]
+	if ns.Labels[LabelManagedBy] != ManagedByValue || ns.Labels[LabelScenario] != "staggered" {
+		t.Fatalf("unexpected labels: %v", ns.Labels)
+	}
+	if ns.Annotations[AnnotationParams] != `{"replicas":"10"}` || ns.Annotations[AnnotationVersion] != "v1.2.3" || ns.Annotations[AnnotationOperator] != "alice@laptop" {
+		t.Fatalf("unexpected annotations: %v", ns.Annotations)
+	}
+}
@@ -0,0 +1,168 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"pvc-protection-bench/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// annProvisionedBy is set by the PV controller and external provisioners on
+// the PVs they create.
+const annProvisionedBy = "pv.kubernetes.io/provisioned-by"
+
+// BoundPVs maps each PVC matching the selector to the PV bound to it. PVCs
+// that are not bound are left out.
+func BoundPVs(ctx context.Context, client kubernetes.Interface, namespace, labelSelector string) (map[string]string, error) {
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pvs := make(map[string]string, len(pvcs.Items))
+	for _, pvc := range pvcs.Items {
+		if pvc.Spec.VolumeName != "" {
+			pvs[pvc.Name] = pvc.Spec.VolumeName
+		}
+	}
+	return pvs, nil
+}
+
+type PVTrackOptions struct {
+	Scenario string
+	PVCSize  string
+	Interval time.Duration
+	// Timeout bounds how long PVs are watched after their PVCs are gone.
+	// Zero leaves it bounded only by the context.
+	Timeout time.Duration
+}
+
+// PVReport accounts for the PVs bound to the deleted PVCs of a run.
+type PVReport struct {
+	Tracked int
+	Deleted int
+	// Released and Remaining name the PVs still present when tracking
+	// stopped, in the Released phase or in any other phase respectively.
+	Released  []string
+	Remaining []string
+	// Latencies holds, for each deleted PV, the time from the deletion of its
+	// PVC to its own.
+	Latencies []time.Duration
+}
+
+// WaitForPVDeletion polls the PVs of the given PVCs until each is gone or has
+// settled in the Released phase under a Retain reclaim policy, which nothing
+// will clean up. PVs still present when the timeout expires are reported,
+// not treated as an error.
+func WaitForPVDeletion(ctx context.Context, client kubernetes.Interface, pvByPVC map[string]string, pvcDeletedAt map[string]time.Time, opts PVTrackOptions) (PVReport, error) {
+	report := PVReport{}
+	pending := map[string]time.Time{}
+	for pvc, pv := range pvByPVC {
+		deletedAt, ok := pvcDeletedAt[pvc]
+		if !ok {
+			continue
+		}
+		pending[pv] = deletedAt
+	}
+	report.Tracked = len(pending)
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+	var deadline <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := map[string]*corev1.PersistentVolume{}
+	for {
+		for name, pvcDeletedAt := range pending {
+			pv, err := client.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				latency := time.Since(pvcDeletedAt)
+				report.Deleted++
+				report.Latencies = append(report.Latencies, latency)
+				metrics.PVDeleteLatency.WithLabelValues(opts.Scenario, opts.PVCSize).Observe(latency.Seconds())
+				delete(pending, name)
+				delete(last, name)
+				continue
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return report, ctx.Err()
+				}
+				metrics.ErrorsTotal.WithLabelValues("pv_get").Inc()
+				return report, fmt.Errorf("failed to get persistent volume %s: %v", name, err)
+			}
+			last[name] = pv
+			if pv.Status.Phase == corev1.VolumeReleased && pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain {
+				delete(pending, name)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			report.classify(last)
+			return report, ctx.Err()
+		case <-deadline:
+			report.classify(last)
+			return report, nil
+		case <-ticker.C:
+		}
+	}
+	report.classify(last)
+	return report, nil
+}
+
+func (r *PVReport) classify(last map[string]*corev1.PersistentVolume) {
+	for name, pv := range last {
+		if pv.Status.Phase == corev1.VolumeReleased {
+			r.Released = append(r.Released, name)
+		} else {
+			r.Remaining = append(r.Remaining, name)
+		}
+	}
+	sort.Strings(r.Released)
+	sort.Strings(r.Remaining)
+}
+
+// DeletePersistentVolume deletes a PV. For a dynamically provisioned PV a
+// Retain reclaim policy is switched to Delete first so that the provisioner
+// also frees the backing storage instead of only the API object being removed.
+func DeletePersistentVolume(ctx context.Context, client kubernetes.Interface, pv *corev1.PersistentVolume) error {
+	if pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain && pv.Annotations[annProvisionedBy] != "" {
+		patch := []byte(`{"spec":{"persistentVolumeReclaimPolicy":"Delete"}}`)
+		_, err := client.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to change reclaim policy of persistent volume %s: %v", pv.Name, err)
+		}
+	}
+	err := client.CoreV1().PersistentVolumes().Delete(ctx, pv.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete persistent volume %s: %v", pv.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestBoundPVs(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-0", Namespace: "pvcbench-test", Labels: map[string]string{"app": "pvcbench-sts"}},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pv-0"},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data-1", Namespace: "pvcbench-test", Labels: map[string]string{"app": "pvcbench-sts"}},
+		},
+	)
+
+	pvs, err := BoundPVs(context.Background(), client, "pvcbench-test", "app=pvcbench-sts")
+	if err != nil {
+		t.Fatalf("BoundPVs error: %v", err)
+	}
+	if len(pvs) != 1 || pvs["data-0"] != "pv-0" {
+		t.Fatalf("expected only data-0 -> pv-0, got %v", pvs)
+	}
+}
+
+func TestWaitForPVDeletion(t *testing.T) {
+	pv := func(name string, phase corev1.PersistentVolumePhase, policy corev1.PersistentVolumeReclaimPolicy) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: policy},
+			Status:     corev1.PersistentVolumeStatus{Phase: phase},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pv("pv-retained", corev1.VolumeReleased, corev1.PersistentVolumeReclaimRetain),
+		pv("pv-failed", corev1.VolumeFailed, corev1.PersistentVolumeReclaimDelete),
+		pv("pv-excluded", corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete),
+	)
+	pvcDeletedAt := time.Now().Add(-time.Second)
+	pvByPVC := map[string]string{
+		"data-0": "pv-gone",
+		"data-1": "pv-retained",
+		"data-2": "pv-failed",
+		"data-3": "pv-excluded",
+	}
+	deletedAt := map[string]time.Time{"data-0": pvcDeletedAt, "data-1": pvcDeletedAt, "data-2": pvcDeletedAt}
+
+	report, err := WaitForPVDeletion(context.Background(), client, pvByPVC, deletedAt, PVTrackOptions{Interval: 10 * time.Millisecond, Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("WaitForPVDeletion error: %v", err)
+	}
+	if report.Tracked != 3 || report.Deleted != 1 {
+		t.Fatalf("expected 3 tracked and 1 deleted, got %+v", report)
+	}
+	if len(report.Latencies) != 1 || report.Latencies[0] < time.Second {
+		t.Fatalf("expected latency measured from pvc deletion, got %v", report.Latencies)
+	}
+	if len(report.Released) != 1 || report.Released[0] != "pv-retained" {
+		t.Fatalf("expected pv-retained to be reported released, got %v", report.Released)
+	}
+	if len(report.Remaining) != 1 || report.Remaining[0] != "pv-failed" {
+		t.Fatalf("expected pv-failed to be reported remaining, got %v", report.Remaining)
+	}
+}
@@ -3,10 +3,14 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"pvc-protection-bench/pkg/logging"
 	"pvc-protection-bench/pkg/metrics"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -26,16 +30,80 @@ func ListPVCNames(ctx context.Context, client kubernetes.Interface, namespace, l
 	return names, nil
 }
 
-func PollPVCDeletion(ctx context.Context, client kubernetes.Interface, namespace string, pvcNames []string, scenario, pvcSize string, replicas int, nsGroup string, pollInterval time.Duration) (_ []time.Duration, errRet error) {
+type PollOptions struct {
+	Scenario string
+	PVCSize  string
+	Replicas int
+	NSGroup  string
+	Interval time.Duration
+	// BatchOf maps a PVC name to the scale-down batch that removed it.
+	// When nil every sample is attributed to batch 0.
+	BatchOf func(pvc string) int
+	// StuckThreshold is how long a PVC may remain after it started
+	// terminating (or after polling started, if it never does) before it is
+	// diagnosed and handled according to StuckPolicy. Zero disables it.
+	StuckThreshold time.Duration
+	StuckPolicy    string
+	Retry          RetryPolicy
+}
+
+// MaxBatchLabels bounds the batch label of the per-batch latency histogram:
+// batches from MaxBatchLabels-1 on are observed in one "N+" series, so the
+// series count does not grow with the replica count.
+const MaxBatchLabels = 10
+
+// BatchLabel returns the batch label value under which a sample of the given
+// batch is exported.
+func BatchLabel(batch int) string {
+	if batch >= MaxBatchLabels-1 {
+		return strconv.Itoa(MaxBatchLabels-1) + "+"
+	}
+	return strconv.Itoa(batch)
+}
+
+type DeletionSample struct {
+	PVC       string
+	Ordinal   int
+	Batch     int
+	DeletedAt time.Time
+	Latency   time.Duration
+}
+
+// PVCOrdinal extracts the StatefulSet ordinal from a PVC name such as
+// data-pvcbench-sts-12. It returns -1 when the name has no numeric suffix.
+func PVCOrdinal(name string) int {
+	idx := strings.LastIndex(name, "-")
+	if idx < 0 {
+		return -1
+	}
+	ordinal, err := strconv.Atoi(name[idx+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+// PollPVCDeletion GETs each PVC until it is gone and records how long it took
+// from its deletionTimestamp. PVCs exceeding the stuck threshold are reported
+// and, with the exclude policy, dropped from tracking. On error, including
+// context cancellation, the samples gathered so far are returned alongside it.
+func PollPVCDeletion(ctx context.Context, client kubernetes.Interface, namespace string, pvcNames []string, opts PollOptions) (_ []DeletionSample, _ []StuckPVCReport, errRet error) {
 	if len(pvcNames) == 0 {
-		return nil, fmt.Errorf("no PVCs found to track deletion")
+		return nil, nil, fmt.Errorf("no PVCs found to track deletion")
 	}
 
+	logger := logging.GetLogger().With(logging.StringField("namespace", namespace))
+	pollStart := time.Now()
+	budget := newErrorBudget(opts.Retry)
+	var stuck []StuckPVCReport
+
 	startTimes := make(map[string]time.Time, len(pvcNames))
 	terminating := make(map[string]bool, len(pvcNames))
 	done := make(map[string]bool, len(pvcNames))
-	latencies := make([]time.Duration, 0, len(pvcNames))
+	samples := make([]DeletionSample, 0, len(pvcNames))
+	replicaStr := fmt.Sprintf("%d", opts.Replicas)
 
+	pollInterval := opts.Interval
 	if pollInterval <= 0 {
 		pollInterval = 500 * time.Millisecond
 	}
@@ -49,30 +117,54 @@ func PollPVCDeletion(ctx context.Context, client kubernetes.Interface, namespace
 
 	for {
 		allDone := true
+		newlyStuck := 0
+		// Built on the first stuck PVC of a pass and shared by the rest.
+		var claims *ClaimIndex
 		for _, name := range pvcNames {
 			if done[name] {
 				continue
 			}
 			allDone = false
 
-			pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+			var pvc *corev1.PersistentVolumeClaim
+			err := budget.do(ctx, "pvc_get", func() error {
+				var err error
+				pvc, err = client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+				return err
+			})
 			if err != nil {
 				if apierrors.IsNotFound(err) {
 					start, ok := startTimes[name]
 					if !ok {
 						start = time.Now()
 					}
-					latency := time.Since(start)
-					latencies = append(latencies, latency)
-					metrics.PVCDeleteLatency.WithLabelValues(scenario, pvcSize, fmt.Sprintf("%d", replicas), nsGroup).Observe(latency.Seconds())
+					deletedAt := time.Now()
+					sample := DeletionSample{
+						PVC:       name,
+						Ordinal:   PVCOrdinal(name),
+						DeletedAt: deletedAt,
+						Latency:   deletedAt.Sub(start),
+					}
+					if opts.BatchOf != nil {
+						sample.Batch = opts.BatchOf(name)
+					}
+					samples = append(samples, sample)
+					metrics.PVCDeleteLatency.WithLabelValues(opts.Scenario, opts.PVCSize, replicaStr, opts.NSGroup).Observe(sample.Latency.Seconds())
+					if opts.BatchOf != nil {
+						metrics.PVCBatchDeleteLatency.WithLabelValues(opts.Scenario, BatchLabel(sample.Batch)).Observe(sample.Latency.Seconds())
+					}
 					if terminating[name] {
 						metrics.PVCsTerminating.Dec()
 					}
 					done[name] = true
 					continue
 				}
+				if ctx.Err() != nil {
+					errRet = ctx.Err()
+					return samples, stuck, errRet
+				}
 				errRet = err
-				return nil, err
+				return samples, stuck, err
 			}
 
 			if pvc.DeletionTimestamp != nil {
@@ -86,6 +178,39 @@ func PollPVCDeletion(ctx context.Context, client kubernetes.Interface, namespace
 					metrics.PVCsTerminating.Inc()
 				}
 			}
+
+			if opts.StuckThreshold > 0 {
+				since, ok := startTimes[name]
+				if !ok {
+					since = pollStart
+				}
+				if stuckFor := time.Since(since); stuckFor > opts.StuckThreshold {
+					if claims == nil {
+						index, err := IndexClaims(ctx, client, namespace)
+						if err != nil {
+							logger.Error("failed to index pods and events of stuck pvcs", logging.ErrorField(err))
+						}
+						claims = &index
+					}
+					report, err := DiagnoseStuckPVC(ctx, client, *claims, pvc, stuckFor)
+					if err != nil {
+						logger.Error("failed to diagnose stuck pvc", logging.StringField("pvc", name), logging.ErrorField(err))
+					}
+					report.Log(logger)
+					metrics.ErrorsTotal.WithLabelValues("pvc_stuck").Inc()
+					stuck = append(stuck, report)
+					newlyStuck++
+					if terminating[name] {
+						metrics.PVCsTerminating.Dec()
+					}
+					done[name] = true
+				}
+			}
+		}
+
+		if newlyStuck > 0 && opts.StuckPolicy != StuckPolicyExclude {
+			errRet = &StuckPVCError{Threshold: opts.StuckThreshold, Reports: stuck}
+			return samples, stuck, errRet
 		}
 
 		if allDone {
@@ -95,10 +220,10 @@ func PollPVCDeletion(ctx context.Context, client kubernetes.Interface, namespace
 		select {
 		case <-ctx.Done():
 			errRet = ctx.Err()
-			return nil, errRet
+			return samples, stuck, errRet
 		case <-ticker.C:
 		}
 	}
 
-	return latencies, nil
+	return samples, stuck, nil
 }
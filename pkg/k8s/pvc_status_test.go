@@ -78,12 +78,68 @@ func TestPollPVCDeletion(t *testing.T) {
 		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "persistentvolumeclaims"}, name)
 	})
 
-	latencies, err := PollPVCDeletion(ctx, client, namespace, pvcNames, "burst", "100Mi", 2, "single", 1*time.Millisecond)
+	samples, _, err := PollPVCDeletion(ctx, client, namespace, pvcNames, PollOptions{
+		Scenario: "batched",
+		PVCSize:  "100Mi",
+		Replicas: 2,
+		NSGroup:  "single",
+		Interval: 1 * time.Millisecond,
+		BatchOf: func(pvc string) int {
+			return PVCOrdinal(pvc) * 10
+		},
+	})
 	if err != nil {
 		t.Fatalf("PollPVCDeletion error: %v", err)
 	}
-	if len(latencies) != len(pvcNames) {
-		t.Fatalf("expected %d latencies, got %d", len(pvcNames), len(latencies))
+	if len(samples) != len(pvcNames) {
+		t.Fatalf("expected %d samples, got %d", len(pvcNames), len(samples))
+	}
+	for _, sample := range samples {
+		if sample.Batch != sample.Ordinal*10 {
+			t.Fatalf("expected %s to be tagged with batch %d, got %d", sample.PVC, sample.Ordinal*10, sample.Batch)
+		}
+		if sample.DeletedAt.IsZero() {
+			t.Fatalf("expected %s to record its deletion time", sample.PVC)
+		}
+	}
+	// Batches 10 and 20 are both past the cap and share one series.
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	var batches []string
+	for _, family := range families {
+		if family.GetName() != "pvcbench_pvc_batch_delete_latency_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["scenario"] == "batched" {
+				batches = append(batches, labels["batch"])
+				if m.GetHistogram().GetSampleCount() != 2 {
+					t.Fatalf("expected both samples in batch %s, got %d", labels["batch"], m.GetHistogram().GetSampleCount())
+				}
+			}
+		}
+	}
+	if len(batches) != 1 || batches[0] != "9+" {
+		t.Fatalf("expected a single capped batch series, got %v", batches)
+	}
+}
+
+func TestBatchLabel(t *testing.T) {
+	seen := map[string]bool{}
+	for batch := 0; batch < 1000; batch++ {
+		seen[BatchLabel(batch)] = true
+	}
+	if len(seen) != MaxBatchLabels {
+		t.Fatalf("expected %d batch label values, got %d", MaxBatchLabels, len(seen))
+	}
+	if BatchLabel(0) != "0" || BatchLabel(8) != "8" || BatchLabel(9) != "9+" || BatchLabel(999) != "9+" {
+		t.Fatalf("unexpected labels: %s %s %s %s", BatchLabel(0), BatchLabel(8), BatchLabel(9), BatchLabel(999))
 	}
 }
 
@@ -107,7 +163,13 @@ func TestPollPVCDeletionResetsTerminatingOnError(t *testing.T) {
 		return true, pvc, apierrors.NewInternalError(fmt.Errorf("boom"))
 	})
 
-	_, err := PollPVCDeletion(ctx, client, namespace, pvcNames, "burst", "100Mi", 1, "single", 1*time.Millisecond)
+	_, _, err := PollPVCDeletion(ctx, client, namespace, pvcNames, PollOptions{
+		Scenario: "burst",
+		PVCSize:  "100Mi",
+		Replicas: 1,
+		NSGroup:  "single",
+		Interval: 1 * time.Millisecond,
+	})
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -116,3 +178,47 @@ func TestPollPVCDeletionResetsTerminatingOnError(t *testing.T) {
 		t.Fatalf("expected PVCsTerminating to be reset to 0, got %v", val)
 	}
 }
+
+func TestPVCOrdinal(t *testing.T) {
+	tests := map[string]int{
+		"data-pvcbench-sts-0":  0,
+		"data-pvcbench-sts-42": 42,
+		"pvc-1":                1,
+		"data":                 -1,
+		"data-pvcbench-sts-x":  -1,
+	}
+	for name, want := range tests {
+		if got := PVCOrdinal(name); got != want {
+			t.Fatalf("PVCOrdinal(%q): expected %d, got %d", name, want, got)
+		}
+	}
+}
+
+func TestPollPVCDeletionReturnsPartialSamplesOnCancel(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	namespace := "test-ns"
+	pvcNames := []string{"pvc-0", "pvc-1"}
+
+	client.PrependReactor("get", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		if name == "pvc-0" {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "persistentvolumeclaims"}, name)
+		}
+		cancel()
+		now := metav1.NewTime(time.Now())
+		return true, &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, DeletionTimestamp: &now},
+		}, nil
+	})
+
+	samples, _, err := PollPVCDeletion(ctx, client, namespace, pvcNames, PollOptions{Scenario: "burst", Interval: time.Millisecond})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(samples) != 1 || samples[0].PVC != "pvc-0" {
+		t.Fatalf("expected partial sample for pvc-0, got %+v", samples)
+	}
+}
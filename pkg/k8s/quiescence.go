@@ -0,0 +1,223 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"pvc-protection-bench/pkg/logging"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	pvcProtectionQueue         = "pvcprotection"
+	controllerManagerSelector  = "component=kube-controller-manager"
+	controllerManagerNamespace = "kube-system"
+	controllerManagerPort      = "10257"
+)
+
+// controllerFinalizers are removed by Kubernetes itself once the object they
+// protect is no longer in use.
+var controllerFinalizers = map[string]bool{
+	"kubernetes.io/pvc-protection":   true,
+	"kubernetes.io/pv-protection":    true,
+	metav1.FinalizerOrphanDependents: true,
+	metav1.FinalizerDeleteDependents: true,
+}
+
+// blockingNamespaceConditions are reported by the namespace controller when it
+// cannot finish deleting a namespace's content.
+var blockingNamespaceConditions = map[corev1.NamespaceConditionType]bool{
+	corev1.NamespaceDeletionDiscoveryFailure: true,
+	corev1.NamespaceDeletionContentFailure:   true,
+	corev1.NamespaceDeletionGVParsingFailure: true,
+}
+
+// QuiescenceReport describes leftovers from earlier runs that would skew a
+// new measurement.
+type QuiescenceReport struct {
+	// Namespaces are pvcbench namespaces that are terminating or still hold
+	// terminating pods or PVCs. Namespaces kept after completed runs are not
+	// leftovers.
+	Namespaces      []string
+	TerminatingPVCs int
+	// Blocked describes leftovers that will not clear without intervention.
+	Blocked []string
+	// WorkqueueDepth is -1 when controller-manager metrics are not reachable.
+	WorkqueueDepth int
+}
+
+func (r QuiescenceReport) Quiet() bool {
+	return len(r.Namespaces) == 0 && r.TerminatingPVCs == 0 && len(r.Blocked) == 0 && r.WorkqueueDepth <= 0
+}
+
+func (r QuiescenceReport) String() string {
+	var reasons []string
+	if len(r.Namespaces) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d pvcbench namespaces still terminating (%s)", len(r.Namespaces), strings.Join(r.Namespaces, ", ")))
+	}
+	if r.TerminatingPVCs > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d PVCs terminating cluster-wide", r.TerminatingPVCs))
+	}
+	if r.WorkqueueDepth > 0 {
+		reasons = append(reasons, fmt.Sprintf("%s workqueue depth is %d", pvcProtectionQueue, r.WorkqueueDepth))
+	}
+	if len(reasons) == 0 {
+		return "cluster is quiet"
+	}
+	return strings.Join(reasons, "; ")
+}
+
+func CheckQuiescence(ctx context.Context, client kubernetes.Interface) (QuiescenceReport, error) {
+	report := QuiescenceReport{WorkqueueDepth: -1}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return report, fmt.Errorf("failed to list PVCs: %v", err)
+	}
+	terminating := map[string][]metav1.ObjectMeta{}
+	for _, pvc := range pvcs.Items {
+		if pvc.DeletionTimestamp != nil {
+			report.TerminatingPVCs++
+			terminating[pvc.Namespace] = append(terminating[pvc.Namespace], pvc.ObjectMeta)
+		}
+	}
+
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: ManagedBySelector})
+	if err != nil {
+		return report, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	for _, ns := range namespaces.Items {
+		for _, pvc := range terminating[ns.Name] {
+			report.Blocked = append(report.Blocked, blockingFinalizers("pvc", pvc)...)
+		}
+		if ns.DeletionTimestamp != nil {
+			report.Namespaces = append(report.Namespaces, ns.Name)
+			for _, c := range ns.Status.Conditions {
+				if blockingNamespaceConditions[c.Type] && c.Status == corev1.ConditionTrue {
+					report.Blocked = append(report.Blocked, fmt.Sprintf("namespace %s: %s", ns.Name, c.Message))
+				}
+			}
+			continue
+		}
+		if len(terminating[ns.Name]) > 0 {
+			report.Namespaces = append(report.Namespaces, ns.Name)
+			continue
+		}
+		pods, err := client.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return report, fmt.Errorf("failed to list pods in %s: %v", ns.Name, err)
+		}
+		podsTerminating := false
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				podsTerminating = true
+				report.Blocked = append(report.Blocked, blockingFinalizers("pod", pod.ObjectMeta)...)
+			}
+		}
+		if podsTerminating {
+			report.Namespaces = append(report.Namespaces, ns.Name)
+		}
+	}
+
+	depth, err := PVCProtectionQueueDepth(ctx, client)
+	if err != nil {
+		logging.GetLogger().Debug("pvcprotection workqueue depth unavailable", logging.ErrorField(err))
+	} else {
+		report.WorkqueueDepth = depth
+	}
+	return report, nil
+}
+
+// blockingFinalizers names the finalizers of a terminating object that no
+// Kubernetes controller removes.
+func blockingFinalizers(kind string, meta metav1.ObjectMeta) []string {
+	var blocked []string
+	for _, f := range meta.Finalizers {
+		if !controllerFinalizers[f] {
+			blocked = append(blocked, fmt.Sprintf("%s %s/%s held by finalizer %s", kind, meta.Namespace, meta.Name, f))
+		}
+	}
+	return blocked
+}
+
+// WaitForQuiescence polls CheckQuiescence until the cluster is quiet. On
+// timeout the error lists what was still outstanding. Leftovers that will not
+// clear on their own fail it right away.
+func WaitForQuiescence(ctx context.Context, client kubernetes.Interface, timeout time.Duration) error {
+	logger := logging.GetLogger()
+	lastLogged := time.Time{}
+	return pollUntil(ctx, "preflight", timeout, func(ctx context.Context) (bool, string, error) {
+		report, err := CheckQuiescence(ctx, client)
+		if err != nil {
+			return false, "", err
+		}
+		if len(report.Blocked) > 0 {
+			return false, "", fmt.Errorf("leftovers of earlier runs will not clear on their own: %s (run pvcbench cleanup --force to remove them)", strings.Join(report.Blocked, "; "))
+		}
+		if report.Quiet() {
+			if report.WorkqueueDepth < 0 {
+				logger.Info("cluster is quiet (pvcprotection workqueue depth not available)")
+			}
+			return true, "", nil
+		}
+		if time.Since(lastLogged) >= readyProgressInterval {
+			logger.Info("waiting for cluster to become quiet", logging.StringField("state", report.String()))
+			lastLogged = time.Now()
+		}
+		return false, report.String(), nil
+	})
+}
+
+// PVCProtectionQueueDepth reads workqueue_depth for the pvcprotection queue
+// from a kube-controller-manager pod through the API server proxy.
+func PVCProtectionQueueDepth(ctx context.Context, client kubernetes.Interface) (int, error) {
+	pods, err := client.CoreV1().Pods(controllerManagerNamespace).List(ctx, metav1.ListOptions{LabelSelector: controllerManagerSelector})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list controller-manager pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		resp := client.CoreV1().Pods(controllerManagerNamespace).ProxyGet("https", pod.Name, controllerManagerPort, "metrics", nil)
+		if resp == nil {
+			return 0, fmt.Errorf("no response from controller-manager pod %s", pod.Name)
+		}
+		body, err := resp.DoRaw(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get metrics from controller-manager pod %s: %v", pod.Name, err)
+		}
+		return parseWorkqueueDepth(body, pvcProtectionQueue)
+	}
+	return 0, fmt.Errorf("no running controller-manager pod found")
+}
+
+func parseWorkqueueDepth(metricsText []byte, queue string) (int, error) {
+	label := fmt.Sprintf(`name="%s"`, queue)
+	scanner := bufio.NewScanner(bytes.NewReader(metricsText))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "workqueue_depth{") || !strings.Contains(line, label) {
+			continue
+		}
+		fields := strings.Fields(line)
+		value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %q: %v", line, err)
+		}
+		return int(value), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("workqueue_depth for %s not found", queue)
+}
@@ -0,0 +1,117 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testControllerMetrics = `# HELP workqueue_depth [ALPHA] Current depth of workqueue
+# TYPE workqueue_depth gauge
+workqueue_depth{name="namespace"} 0
+workqueue_depth{name="pvcprotection"} 42
+`
+
+type fakeProxyResponse struct {
+	body []byte
+}
+
+func (r fakeProxyResponse) DoRaw(context.Context) ([]byte, error) {
+	return r.body, nil
+}
+
+func (r fakeProxyResponse) Stream(context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(r.body))), nil
+}
+
+func TestParseWorkqueueDepth(t *testing.T) {
+	depth, err := parseWorkqueueDepth([]byte(testControllerMetrics), "pvcprotection")
+	if err != nil {
+		t.Fatalf("parseWorkqueueDepth error: %v", err)
+	}
+	if depth != 42 {
+		t.Fatalf("expected depth 42, got %d", depth)
+	}
+	if _, err := parseWorkqueueDepth([]byte(testControllerMetrics), "missing"); err == nil {
+		t.Fatalf("expected error for missing queue")
+	}
+}
+
+func TestCheckQuiescence(t *testing.T) {
+	now := metav1.Now()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-123", Labels: RunMetadata{}.Labels(), DeletionTimestamp: &now, Finalizers: []string{"kubernetes"}}},
+		// Completed runs keep their namespace; only one with terminating
+		// content is a leftover.
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-kept", Labels: RunMetadata{}.Labels()}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-draining", Labels: RunMetadata{}.Labels()}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-sts-0", Namespace: "pvcbench-draining", DeletionTimestamp: &now, Finalizers: []string{metav1.FinalizerDeleteDependents}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-0", Namespace: "apps", DeletionTimestamp: &now, Finalizers: []string{"kubernetes.io/pvc-protection"}}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-1", Namespace: "apps"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "kube-controller-manager-minikube", Namespace: "kube-system", Labels: map[string]string{"component": "kube-controller-manager"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+	)
+	client.PrependProxyReactor("pods", func(action k8stesting.Action) (bool, restclient.ResponseWrapper, error) {
+		return true, fakeProxyResponse{body: []byte(testControllerMetrics)}, nil
+	})
+
+	report, err := CheckQuiescence(context.Background(), client)
+	if err != nil {
+		t.Fatalf("CheckQuiescence error: %v", err)
+	}
+	if strings.Join(report.Namespaces, ",") != "pvcbench-123,pvcbench-draining" {
+		t.Fatalf("unexpected namespaces: %v", report.Namespaces)
+	}
+	if len(report.Blocked) != 0 {
+		t.Fatalf("expected nothing to be blocked, got %v", report.Blocked)
+	}
+	if report.TerminatingPVCs != 1 {
+		t.Fatalf("expected 1 terminating pvc, got %d", report.TerminatingPVCs)
+	}
+	if report.WorkqueueDepth != 42 {
+		t.Fatalf("expected workqueue depth 42, got %d", report.WorkqueueDepth)
+	}
+	if report.Quiet() {
+		t.Fatalf("expected cluster not to be quiet")
+	}
+}
+
+func TestWaitForQuiescence(t *testing.T) {
+	if err := WaitForQuiescence(context.Background(), fake.NewSimpleClientset(), time.Second); err != nil {
+		t.Fatalf("expected empty cluster to be quiet: %v", err)
+	}
+
+	kept := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-kept", Labels: RunMetadata{}.Labels()}}
+	if err := WaitForQuiescence(context.Background(), fake.NewSimpleClientset(kept), time.Second); err != nil {
+		t.Fatalf("expected a namespace kept after a completed run not to block preflight: %v", err)
+	}
+
+	now := metav1.Now()
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-123", Labels: RunMetadata{}.Labels(), DeletionTimestamp: &now, Finalizers: []string{"kubernetes"}}})
+	err := WaitForQuiescence(context.Background(), client, 10*time.Millisecond)
+	var timeoutErr *PhaseTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Phase != "preflight" {
+		t.Fatalf("expected preflight timeout, got %v", err)
+	}
+	if !strings.Contains(timeoutErr.State, "pvcbench-123") {
+		t.Fatalf("expected state to name the leftover namespace, got %q", timeoutErr.State)
+	}
+
+	held := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data-0", Namespace: "pvcbench-kept", DeletionTimestamp: &now, Finalizers: []string{"kubernetes.io/pvc-protection", "example.com/hold"}}}
+	err = WaitForQuiescence(context.Background(), fake.NewSimpleClientset(kept, held), time.Minute)
+	if err == nil || errors.As(err, &timeoutErr) || !strings.Contains(err.Error(), "example.com/hold") || !strings.Contains(err.Error(), "pvcbench cleanup") {
+		t.Fatalf("expected a blocked pvc to fail preflight right away, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"pvc-protection-bench/pkg/logging"
+	"pvc-protection-bench/pkg/metrics"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RetryPolicy controls how transient API errors are handled while measuring.
+// The zero value fails on the first error.
+type RetryPolicy struct {
+	// ErrorBudget is the number of retriable errors tolerated over a whole
+	// poll before it fails.
+	ErrorBudget    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func (p RetryPolicy) backoff() wait.Backoff {
+	return wait.Backoff{
+		Duration: p.InitialBackoff,
+		Factor:   2,
+		Jitter:   0.5,
+		Steps:    math.MaxInt32,
+		Cap:      p.MaxBackoff,
+	}
+}
+
+// ErrorClass buckets an API error as throttled, timeout, server_error or
+// connection_reset. It returns "" for errors that should not be retried.
+func ErrorClass(err error) string {
+	var netErr net.Error
+	var status apierrors.APIStatus
+	switch {
+	case err == nil:
+		return ""
+	case apierrors.IsTooManyRequests(err):
+		return "throttled"
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return "timeout"
+	case errors.As(err, &status) && status.Status().Code >= 500:
+		return "server_error"
+	case utilnet.IsConnectionReset(err), utilnet.IsProbableEOF(err):
+		return "connection_reset"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	}
+	return ""
+}
+
+// errorBudget retries retriable errors with jittered exponential backoff until
+// the policy's budget is spent. The backoff is reset after each success.
+type errorBudget struct {
+	policy    RetryPolicy
+	remaining int
+	backoff   wait.Backoff
+}
+
+func newErrorBudget(policy RetryPolicy) *errorBudget {
+	return &errorBudget{policy: policy, remaining: policy.ErrorBudget, backoff: policy.backoff()}
+}
+
+func (b *errorBudget) do(ctx context.Context, op string, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil || apierrors.IsNotFound(err) {
+			b.backoff = b.policy.backoff()
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		class := ErrorClass(err)
+		if class == "" {
+			return err
+		}
+		metrics.ErrorsTotal.WithLabelValues(op + "_" + class).Inc()
+		if b.remaining <= 0 {
+			return fmt.Errorf("%s: error budget of %d exhausted: %v", op, b.policy.ErrorBudget, err)
+		}
+		b.remaining--
+
+		delay := b.backoff.Step()
+		logging.GetLogger().Warn("retrying transient api error",
+			logging.StringField("op", op),
+			logging.StringField("class", class),
+			logging.StringField("backoff", delay.String()),
+			logging.StringField("budget_remaining", fmt.Sprintf("%d", b.remaining)),
+			logging.ErrorField(err),
+		)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
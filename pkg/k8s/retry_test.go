@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestErrorClass(t *testing.T) {
+	gr := schema.GroupResource{Resource: "persistentvolumeclaims"}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{apierrors.NewTooManyRequests("slow down", 1), "throttled"},
+		{apierrors.NewServerTimeout(gr, "get", 1), "timeout"},
+		{apierrors.NewTimeoutError("timeout", 1), "timeout"},
+		{apierrors.NewInternalError(errors.New("boom")), "server_error"},
+		{apierrors.NewServiceUnavailable("unavailable"), "server_error"},
+		{fmt.Errorf("get pvc: %w", syscall.ECONNRESET), "connection_reset"},
+		{apierrors.NewNotFound(gr, "pvc"), ""},
+		{apierrors.NewForbidden(gr, "pvc", errors.New("denied")), ""},
+		{errors.New("unexpected"), ""},
+	}
+	for _, tt := range tests {
+		if got := ErrorClass(tt.err); got != tt.want {
+			t.Fatalf("ErrorClass(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func throttlingReactor(failures int) k8stesting.ReactionFunc {
+	calls := 0
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls <= failures {
+			return true, nil, apierrors.NewTooManyRequests("slow down", 1)
+		}
+		name := action.(k8stesting.GetAction).GetName()
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "persistentvolumeclaims"}, name)
+	}
+}
+
+func TestPollPVCDeletionRetriesTransientErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	opts := PollOptions{
+		Interval: time.Millisecond,
+		Retry:    RetryPolicy{ErrorBudget: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+	}
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "persistentvolumeclaims", throttlingReactor(3))
+	samples, _, err := PollPVCDeletion(ctx, client, "test-ns", []string{"pvc-0"}, opts)
+	if err != nil {
+		t.Fatalf("expected errors within budget to be retried, got %v", err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+
+	client = fake.NewSimpleClientset()
+	client.PrependReactor("get", "persistentvolumeclaims", throttlingReactor(4))
+	_, _, err = PollPVCDeletion(ctx, client, "test-ns", []string{"pvc-0"}, opts)
+	if err == nil || !strings.Contains(err.Error(), "error budget of 3 exhausted") {
+		t.Fatalf("expected exhausted error budget, got %v", err)
+	}
+}
+
+func TestPollPVCDeletionDoesNotRetryPermanentErrors(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	calls := 0
+	client.PrependReactor("get", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		return true, &corev1.PersistentVolumeClaim{}, apierrors.NewForbidden(schema.GroupResource{Resource: "persistentvolumeclaims"}, "pvc-0", errors.New("denied"))
+	})
+	opts := PollOptions{Interval: time.Millisecond, Retry: RetryPolicy{ErrorBudget: 10}}
+	if _, _, err := PollPVCDeletion(context.Background(), client, "test-ns", []string{"pvc-0"}, opts); !apierrors.IsForbidden(err) {
+		t.Fatalf("expected forbidden error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single GET, got %d", calls)
+	}
+}
@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LabelManagedBy     = "app.kubernetes.io/managed-by"
+	ManagedByValue     = "pvcbench"
+	LabelRunID         = "pvcbench.io/run-id"
+	LabelScenario      = "pvcbench.io/scenario"
+	AnnotationParams   = "pvcbench.io/params"
+	AnnotationVersion  = "pvcbench.io/tool-version"
+	AnnotationOperator = "pvcbench.io/operator"
+)
+
+// ManagedBySelector selects every resource created by pvcbench.
+var ManagedBySelector = LabelManagedBy + "=" + ManagedByValue
+
+// RunMetadata identifies the run that created a resource. It is attached as
+// labels (for selection) and annotations (for people reading the objects).
+type RunMetadata struct {
+	RunID       string
+	Scenario    string
+	Params      map[string]string
+	ToolVersion string
+	Operator    string
+}
+
+func NewRunID() string {
+	return uuid.NewString()
+}
+
+// RunNamespace is the namespace name used for a run.
+func RunNamespace(runID string) string {
+	return NamespacePrefix + runID
+}
+
+// Operator names the person running pvcbench as user@host.
+func Operator() string {
+	host, _ := os.Hostname()
+	user := os.Getenv("USER")
+	if user == "" {
+		user = "unknown"
+	}
+	return fmt.Sprintf("%s@%s", user, host)
+}
+
+func (m RunMetadata) Labels() map[string]string {
+	labels := map[string]string{LabelManagedBy: ManagedByValue}
+	if m.RunID != "" {
+		labels[LabelRunID] = m.RunID
+	}
+	if m.Scenario != "" {
+		labels[LabelScenario] = m.Scenario
+	}
+	return labels
+}
+
+func (m RunMetadata) Annotations() map[string]string {
+	annotations := map[string]string{}
+	if len(m.Params) > 0 {
+		if params, err := json.Marshal(m.Params); err == nil {
+			annotations[AnnotationParams] = string(params)
+		}
+	}
+	if m.ToolVersion != "" {
+		annotations[AnnotationVersion] = m.ToolVersion
+	}
+	if m.Operator != "" {
+		annotations[AnnotationOperator] = m.Operator
+	}
+	return annotations
+}
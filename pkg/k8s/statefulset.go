@@ -2,13 +2,26 @@ package k8s
 
 import (
 	"context"
+	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	autoscalingv1ac "k8s.io/client-go/applyconfigurations/autoscaling/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// PauseImage is the container image of every benchmark pod.
+	PauseImage = "registry.k8s.io/pause:3.9"
+
+	// FieldManager owns the fields pvcbench sets on the objects it creates
+	// and scales.
+	FieldManager = "pvcbench"
 )
 
 type StatefulSetConfig struct {
@@ -16,86 +29,128 @@ type StatefulSetConfig struct {
 	Namespace string
 	Replicas  int32
 	PVCSize   string
+	Run       RunMetadata
+	// StaticPVs, when set, has ProvisionStaticPVs create the PVCs up front
+	// instead of leaving them to the StatefulSet and a provisioner.
+	StaticPVs *StaticPVOptions
+	// Shards splits Replicas across this many StatefulSets named
+	// <Name>-0..<Name>-<Shards-1>. Zero or one keeps a single StatefulSet
+	// named Name.
+	Shards int
+}
+
+// ShardConfigs returns one single-StatefulSet config per shard. Replicas are
+// spread as evenly as possible, earlier shards taking the remainder.
+func (c StatefulSetConfig) ShardConfigs() []StatefulSetConfig {
+	if c.Shards <= 1 {
+		single := c
+		single.Shards = 0
+		return []StatefulSetConfig{single}
+	}
+	shards := make([]StatefulSetConfig, 0, c.Shards)
+	base, extra := c.Replicas/int32(c.Shards), c.Replicas%int32(c.Shards)
+	for i := 0; i < c.Shards; i++ {
+		shard := c
+		shard.Shards = 0
+		shard.Name = fmt.Sprintf("%s-%d", c.Name, i)
+		shard.Replicas = base
+		if int32(i) < extra {
+			shard.Replicas++
+		}
+		shards = append(shards, shard)
+	}
+	return shards
 }
 
+// CreateStatefulSet server-side applies the StatefulSet of config, so the
+// fields it sets are owned by FieldManager in managedFields.
 func CreateStatefulSet(ctx context.Context, client kubernetes.Interface, config StatefulSetConfig) (*appsv1.StatefulSet, error) {
 	deletePolicy := appsv1.DeletePersistentVolumeClaimRetentionPolicyType
-	labels := map[string]string{
-		"app": config.Name,
+	labels := config.Run.Labels()
+	labels["app"] = config.Name
+	annotations := config.Run.Annotations()
+	size, err := resource.ParseQuantity(config.PVCSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pvc size %q: %v", config.PVCSize, err)
 	}
 
-	sts := &appsv1.StatefulSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      config.Name,
-			Namespace: config.Namespace,
-		},
-		Spec: appsv1.StatefulSetSpec{
-			Replicas: &config.Replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": config.Name,
-				},
-			},
-			PodManagementPolicy: appsv1.ParallelPodManagement,
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name:  "pause",
-							Image: "registry.k8s.io/pause:3.9",
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "data",
-									MountPath: "/mnt/data",
-								},
-							},
-						},
-					},
-				},
-			},
-			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:   "data",
-						Labels: labels,
-					},
-					Spec: corev1.PersistentVolumeClaimSpec{
-						AccessModes: []corev1.PersistentVolumeAccessMode{
-							corev1.ReadWriteOnce,
-						},
-						Resources: corev1.VolumeResourceRequirements{
-							Requests: corev1.ResourceList{
-								corev1.ResourceStorage: resource.MustParse(config.PVCSize),
-							},
-						},
-					},
-				},
-			},
-			PersistentVolumeClaimRetentionPolicy: &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
-				WhenScaled:  deletePolicy,
-				WhenDeleted: deletePolicy,
-			},
-		},
+	claimSpec := corev1ac.PersistentVolumeClaimSpec().
+		WithAccessModes(corev1.ReadWriteOnce).
+		WithResources(corev1ac.VolumeResourceRequirements().
+			WithRequests(corev1.ResourceList{corev1.ResourceStorage: size}))
+	if config.StaticPVs != nil {
+		claimSpec.WithStorageClassName(StaticStorageClass)
 	}
 
-	return client.AppsV1().StatefulSets(config.Namespace).Create(ctx, sts, metav1.CreateOptions{})
+	sts := appsv1ac.StatefulSet(config.Name, config.Namespace).
+		WithLabels(labels).
+		WithAnnotations(annotations).
+		WithSpec(appsv1ac.StatefulSetSpec().
+			WithReplicas(config.Replicas).
+			WithSelector(metav1ac.LabelSelector().WithMatchLabels(map[string]string{"app": config.Name})).
+			WithPodManagementPolicy(appsv1.ParallelPodManagement).
+			WithTemplate(corev1ac.PodTemplateSpec().
+				WithLabels(labels).
+				WithSpec(corev1ac.PodSpec().
+					WithContainers(corev1ac.Container().
+						WithName("pause").
+						WithImage(PauseImage).
+						WithVolumeMounts(corev1ac.VolumeMount().
+							WithName(volumeClaimTemplate).
+							WithMountPath("/mnt/data"))))).
+			WithVolumeClaimTemplates((&corev1ac.PersistentVolumeClaimApplyConfiguration{}).
+				WithName(volumeClaimTemplate).
+				WithLabels(labels).
+				WithSpec(claimSpec)).
+			WithPersistentVolumeClaimRetentionPolicy(appsv1ac.StatefulSetPersistentVolumeClaimRetentionPolicy().
+				WithWhenScaled(deletePolicy).
+				WithWhenDeleted(deletePolicy)))
+
+	return client.AppsV1().StatefulSets(config.Namespace).Apply(ctx, sts, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
 }
 
+// ScaleStatefulSet applies the replica count through the scale subresource:
+// a single request that cannot conflict with the controller's status writes.
 func ScaleStatefulSet(ctx context.Context, client kubernetes.Interface, namespace, name string, replicas int32) error {
-	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
-		sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-		sts.Spec.Replicas = &replicas
-		_, err = client.AppsV1().StatefulSets(namespace).Update(ctx, sts, metav1.UpdateOptions{})
-		return err
-	})
+	scale := autoscalingv1ac.Scale().WithSpec(autoscalingv1ac.ScaleSpec().WithReplicas(replicas))
+	_, err := client.AppsV1().StatefulSets(namespace).ApplyScale(ctx, name, scale, metav1.ApplyOptions{FieldManager: FieldManager, Force: true})
+	return err
 }
 
 func DeleteStatefulSet(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
 	return client.AppsV1().StatefulSets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
 }
+
+// StatefulSetReusable reports whether an existing StatefulSet was created for
+// the same configuration, so that a run can keep it instead of recreating it.
+// Its replica count must be the configured one or 0, which is how every
+// completed run leaves it; the caller scales it back up.
+func StatefulSetReusable(sts *appsv1.StatefulSet, config StatefulSetConfig) bool {
+	if sts.DeletionTimestamp != nil || sts.Spec.Replicas == nil {
+		return false
+	}
+	if *sts.Spec.Replicas != config.Replicas && *sts.Spec.Replicas != 0 {
+		return false
+	}
+	containers := sts.Spec.Template.Spec.Containers
+	if len(containers) != 1 || containers[0].Image != PauseImage {
+		return false
+	}
+	policy := sts.Spec.PersistentVolumeClaimRetentionPolicy
+	if policy == nil || policy.WhenScaled != appsv1.DeletePersistentVolumeClaimRetentionPolicyType {
+		return false
+	}
+	if len(sts.Spec.VolumeClaimTemplates) != 1 {
+		return false
+	}
+	size, err := resource.ParseQuantity(config.PVCSize)
+	if err != nil {
+		return false
+	}
+	template := sts.Spec.VolumeClaimTemplates[0].Spec
+	if (template.StorageClassName != nil && *template.StorageClassName == StaticStorageClass) != (config.StaticPVs != nil) {
+		return false
+	}
+	requested := template.Resources.Requests[corev1.ResourceStorage]
+	return requested.Cmp(size) == 0
+}
@@ -2,15 +2,22 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestCreateStatefulSetSpec(t *testing.T) {
-	client := fake.NewSimpleClientset()
+	client := fake.NewClientset()
 	ctx := context.Background()
 
 	config := StatefulSetConfig{
@@ -18,6 +25,7 @@ func TestCreateStatefulSetSpec(t *testing.T) {
 		Namespace: "pvcbench-1",
 		Replicas:  5,
 		PVCSize:   "100Mi",
+		Run:       RunMetadata{RunID: "run-1", Scenario: "burst", Operator: "alice@host"},
 	}
 
 	sts, err := CreateStatefulSet(ctx, client, config)
@@ -45,8 +53,104 @@ func TestCreateStatefulSetSpec(t *testing.T) {
 	if pvc.ObjectMeta.Labels["app"] != config.Name {
 		t.Fatalf("expected pvc label app=%s", config.Name)
 	}
+	if pvc.ObjectMeta.Labels[LabelRunID] != "run-1" || sts.Spec.Template.Labels[LabelRunID] != "run-1" {
+		t.Fatalf("expected pvc and pod templates to carry the run id label")
+	}
+	if sts.Annotations[AnnotationOperator] != "alice@host" {
+		t.Fatalf("expected operator annotation, got %v", sts.Annotations)
+	}
 	req := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
 	if req.String() != config.PVCSize {
 		t.Fatalf("expected pvc size %s, got %s", config.PVCSize, req.String())
 	}
+	if len(sts.ManagedFields) != 1 || sts.ManagedFields[0].Manager != FieldManager || sts.ManagedFields[0].Operation != metav1.ManagedFieldsOperationApply {
+		t.Fatalf("expected fields applied by %s, got %+v", FieldManager, sts.ManagedFields)
+	}
+
+	// Applying the same config again must not fail on the existing object.
+	if _, err := CreateStatefulSet(ctx, client, config); err != nil {
+		t.Fatalf("reapply error: %v", err)
+	}
+}
+
+func TestScaleStatefulSet(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	var scaled *autoscalingv1.Scale
+	client.PrependReactor("patch", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchActionImpl)
+		if patch.GetSubresource() != "scale" || patch.GetPatchType() != types.ApplyPatchType || patch.GetPatchOptions().FieldManager != FieldManager {
+			t.Errorf("expected an apply of the scale subresource by %s, got %s %s %+v", FieldManager, patch.GetSubresource(), patch.GetPatchType(), patch.GetPatchOptions())
+		}
+		scaled = &autoscalingv1.Scale{}
+		if err := json.Unmarshal(patch.GetPatch(), scaled); err != nil {
+			return true, nil, err
+		}
+		return true, scaled, nil
+	})
+
+	if err := ScaleStatefulSet(context.Background(), client, "pvcbench-1", "pvcbench-sts", 0); err != nil {
+		t.Fatalf("ScaleStatefulSet error: %v", err)
+	}
+	if len(client.Actions()) != 1 {
+		t.Fatalf("expected a single request, got %v", client.Actions())
+	}
+	if scaled == nil || scaled.Spec.Replicas != 0 {
+		t.Fatalf("expected replicas 0 to be applied, got %+v", scaled)
+	}
+}
+
+func TestStatefulSetReusable(t *testing.T) {
+	config := StatefulSetConfig{Name: "pvcbench-sts", Namespace: "pvcbench-dev", Replicas: 3, PVCSize: "100Mi"}
+	created, err := CreateStatefulSet(context.Background(), fake.NewClientset(), config)
+	if err != nil {
+		t.Fatalf("CreateStatefulSet error: %v", err)
+	}
+
+	if !StatefulSetReusable(created, config) {
+		t.Fatalf("expected a statefulset with the same config to be reusable")
+	}
+	scaledDown := created.DeepCopy()
+	zero := int32(0)
+	scaledDown.Spec.Replicas = &zero
+	if !StatefulSetReusable(scaledDown, config) {
+		t.Fatalf("expected a statefulset scaled to 0 by a completed run to be reusable")
+	}
+
+	other := config
+	other.PVCSize = "1Gi"
+	if StatefulSetReusable(created, other) || StatefulSetReusable(scaledDown, other) {
+		t.Fatalf("expected a different pvc size not to be reusable")
+	}
+	other = config
+	other.Replicas = 5
+	if StatefulSetReusable(created, other) {
+		t.Fatalf("expected a different replica count not to be reusable")
+	}
+	other = config
+	other.StaticPVs = &StaticPVOptions{Type: StaticPVHostPath}
+	if StatefulSetReusable(scaledDown, other) {
+		t.Fatalf("expected a dynamically provisioned statefulset not to be reused for static pvs")
+	}
+}
+
+func TestShardConfigs(t *testing.T) {
+	single := StatefulSetConfig{Name: "pvcbench-sts", Replicas: 10}.ShardConfigs()
+	if len(single) != 1 || single[0].Name != "pvcbench-sts" || single[0].Replicas != 10 {
+		t.Fatalf("expected an unsharded config to keep its name, got %+v", single)
+	}
+
+	shards := StatefulSetConfig{Name: "pvcbench-sts", Namespace: "pvcbench-1", Replicas: 10, Shards: 3}.ShardConfigs()
+	if len(shards) != 3 {
+		t.Fatalf("expected 3 shards, got %d", len(shards))
+	}
+	var total int32
+	for i, shard := range shards {
+		if shard.Name != fmt.Sprintf("pvcbench-sts-%d", i) || shard.Namespace != "pvcbench-1" || shard.Shards != 0 {
+			t.Fatalf("unexpected shard %d: %+v", i, shard)
+		}
+		total += shard.Replicas
+	}
+	if total != 10 || shards[0].Replicas != 4 || shards[2].Replicas != 3 {
+		t.Fatalf("expected replicas 4/3/3, got %d/%d/%d", shards[0].Replicas, shards[1].Replicas, shards[2].Replicas)
+	}
 }
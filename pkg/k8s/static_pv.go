@@ -0,0 +1,212 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	StaticPVHostPath = "hostPath"
+	StaticPVLocal    = "local"
+
+	// StaticStorageClass is the class of static PVs and their PVCs. It needs
+	// no StorageClass object and keeps dynamic provisioners away from them.
+	StaticStorageClass = "pvcbench-static"
+
+	volumeClaimTemplate = "data"
+)
+
+// StaticPVOptions replaces dynamic provisioning with PVs created up front and
+// PVCs pre-bound to them.
+type StaticPVOptions struct {
+	Type string
+	// Path is the directory every PV points at. The pause container never
+	// writes to it, so the PVs can share it. Local PVs need it to exist.
+	Path string
+	// Nodes pins local PVs round-robin by ordinal. Unused for hostPath.
+	Nodes   []string
+	Workers int
+}
+
+// PVCName is the name the StatefulSet controller gives the PVC of an ordinal.
+func PVCName(statefulSet string, ordinal int) string {
+	return fmt.Sprintf("%s-%s-%d", volumeClaimTemplate, statefulSet, ordinal)
+}
+
+// StaticPVName is cluster-wide, so it includes the namespace.
+func StaticPVName(namespace, statefulSet string, ordinal int) string {
+	return namespace + "-" + PVCName(statefulSet, ordinal)
+}
+
+// ProvisionStaticPVs creates a PV and a PVC bound to it for every ordinal of
+// the StatefulSet, using up to opts.Workers concurrent workers. Existing
+// objects are left in place so that a partially provisioned run can resume.
+// They are created rather than applied: the binder completes the claimRef,
+// which a forced apply of the atomic reference would reset.
+func ProvisionStaticPVs(ctx context.Context, client kubernetes.Interface, config StatefulSetConfig) error {
+	if config.StaticPVs == nil {
+		return nil
+	}
+	size, err := resource.ParseQuantity(config.PVCSize)
+	if err != nil {
+		return fmt.Errorf("invalid pvc size %q: %v", config.PVCSize, err)
+	}
+	return forEachOrdinal(ctx, config, func(ctx context.Context, ordinal int) error {
+		return provisionStaticPV(ctx, client, config, size, ordinal)
+	})
+}
+
+// DeleteStaticPVs deletes the static PVs of every ordinal of the StatefulSet.
+// Their Retain policy keeps them after the PVCs are gone.
+func DeleteStaticPVs(ctx context.Context, client kubernetes.Interface, config StatefulSetConfig) error {
+	if config.StaticPVs == nil {
+		return nil
+	}
+	return forEachOrdinal(ctx, config, func(ctx context.Context, ordinal int) error {
+		name := StaticPVName(config.Namespace, config.Name, ordinal)
+		err := client.CoreV1().PersistentVolumes().Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete persistent volume %s: %v", name, err)
+		}
+		return nil
+	})
+}
+
+// forEachOrdinal calls fn for every ordinal of the StatefulSet from up to
+// StaticPVs.Workers goroutines. The first error cancels the rest.
+func forEachOrdinal(ctx context.Context, config StatefulSetConfig, fn func(ctx context.Context, ordinal int) error) error {
+	workers := config.StaticPVs.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		once     sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	ordinals := make(chan int)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ordinal := range ordinals {
+				if err := fn(ctx, ordinal); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for ordinal := 0; ordinal < int(config.Replicas); ordinal++ {
+		select {
+		case ordinals <- ordinal:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ordinals)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+func provisionStaticPV(ctx context.Context, client kubernetes.Interface, config StatefulSetConfig, size resource.Quantity, ordinal int) error {
+	opts := config.StaticPVs
+	pvcName := PVCName(config.Name, ordinal)
+	pvName := StaticPVName(config.Namespace, config.Name, ordinal)
+	labels := config.Run.Labels()
+	labels["app"] = config.Name
+	storageClass := StaticStorageClass
+
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: pvName, Labels: labels},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: size},
+			AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			StorageClassName:              storageClass,
+			ClaimRef:                      &corev1.ObjectReference{Kind: "PersistentVolumeClaim", APIVersion: "v1", Namespace: config.Namespace, Name: pvcName},
+		},
+	}
+	switch opts.Type {
+	case StaticPVLocal:
+		pv.Spec.Local = &corev1.LocalVolumeSource{Path: opts.Path}
+		if len(opts.Nodes) > 0 {
+			pv.Spec.NodeAffinity = &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      corev1.LabelHostname,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{opts.Nodes[ordinal%len(opts.Nodes)]},
+				}}}},
+			}}
+		}
+	default:
+		hostPathType := corev1.HostPathDirectoryOrCreate
+		pv.Spec.HostPath = &corev1.HostPathVolumeSource{Path: opts.Path, Type: &hostPathType}
+	}
+	_, err := client.CoreV1().PersistentVolumes().Create(ctx, pv, metav1.CreateOptions{FieldManager: FieldManager})
+	if apierrors.IsAlreadyExists(err) {
+		err = releaseStaleClaim(ctx, client, pvName, config.Namespace, pvcName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create persistent volume %s: %v", pvName, err)
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: config.Namespace, Labels: labels},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClass,
+			VolumeName:       pvName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+	if _, err := client.CoreV1().PersistentVolumeClaims(config.Namespace).Create(ctx, pvc, metav1.CreateOptions{FieldManager: FieldManager}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create persistent volume claim %s: %v", pvcName, err)
+	}
+	return nil
+}
+
+// releaseStaleClaim makes a PV left by an earlier run bindable again. Once its
+// PVC is deleted a Retain PV is Released and keeps the UID of that PVC in its
+// claimRef, so the new PVC of the same name would never bind to it. Dropping
+// the UID returns the PV to Available, still reserved for the PVC name.
+func releaseStaleClaim(ctx context.Context, client kubernetes.Interface, pvName, namespace, pvcName string) error {
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	ref := pv.Spec.ClaimRef
+	if ref == nil || ref.UID == "" {
+		return nil
+	}
+	pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metav1.GetOptions{})
+	if err == nil && pvc.UID == ref.UID {
+		return nil
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	patch := []byte(`{"spec":{"claimRef":{"uid":null,"resourceVersion":null}}}`)
+	_, err = client.CoreV1().PersistentVolumes().Patch(ctx, pvName, types.MergePatchType, patch, metav1.PatchOptions{FieldManager: FieldManager})
+	return err
+}
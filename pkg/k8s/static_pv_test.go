@@ -0,0 +1,170 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestProvisionStaticPVs(t *testing.T) {
+	ctx := context.Background()
+	config := StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: "pvcbench-test",
+		Replicas:  5,
+		PVCSize:   "100Mi",
+		Run:       RunMetadata{RunID: "run-1"},
+		StaticPVs: &StaticPVOptions{Type: StaticPVLocal, Path: "/mnt/pvcbench", Nodes: []string{"node-a", "node-b"}, Workers: 3},
+	}
+	// An object left by an interrupted earlier attempt must not fail the run.
+	client := fake.NewSimpleClientset(&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: StaticPVName(config.Namespace, config.Name, 0)}})
+
+	if err := ProvisionStaticPVs(ctx, client, config); err != nil {
+		t.Fatalf("ProvisionStaticPVs error: %v", err)
+	}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(config.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list pvcs: %v", err)
+	}
+	if len(pvcs.Items) != 5 {
+		t.Fatalf("expected 5 pvcs, got %d", len(pvcs.Items))
+	}
+	for _, pvc := range pvcs.Items {
+		ordinal := PVCOrdinal(pvc.Name)
+		if pvc.Name != PVCName(config.Name, ordinal) || pvc.Spec.VolumeName != StaticPVName(config.Namespace, config.Name, ordinal) {
+			t.Fatalf("unexpected pvc binding: %s -> %s", pvc.Name, pvc.Spec.VolumeName)
+		}
+		if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName != StaticStorageClass {
+			t.Fatalf("expected pvc %s to use the static storage class", pvc.Name)
+		}
+	}
+
+	pv, err := client.CoreV1().PersistentVolumes().Get(ctx, StaticPVName(config.Namespace, config.Name, 3), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pv: %v", err)
+	}
+	if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.Name != "data-pvcbench-sts-3" || pv.Spec.ClaimRef.Namespace != config.Namespace {
+		t.Fatalf("unexpected claimRef: %+v", pv.Spec.ClaimRef)
+	}
+	if pv.Spec.Local == nil || pv.Spec.Local.Path != "/mnt/pvcbench" {
+		t.Fatalf("expected a local volume, got %+v", pv.Spec.PersistentVolumeSource)
+	}
+	node := pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions[0].Values[0]
+	if node != "node-b" {
+		t.Fatalf("expected ordinal 3 on node-b, got %s", node)
+	}
+	if pv.Labels[LabelRunID] != "run-1" {
+		t.Fatalf("expected run labels on pv, got %v", pv.Labels)
+	}
+}
+
+func TestProvisionStaticPVsStopsOnError(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "persistentvolumes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("persistentvolumes"), "", errors.New("denied"))
+	})
+	config := StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: "pvcbench-test",
+		Replicas:  50,
+		PVCSize:   "100Mi",
+		StaticPVs: &StaticPVOptions{Type: StaticPVHostPath, Path: "/tmp/pvcbench", Workers: 4},
+	}
+
+	err := ProvisionStaticPVs(context.Background(), client, config)
+	if err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Fatalf("expected forbidden error, got %v", err)
+	}
+	creates := 0
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "create" {
+			creates++
+		}
+	}
+	if creates >= 50 {
+		t.Fatalf("expected provisioning to stop early, got %d creates", creates)
+	}
+}
+
+func TestProvisionStaticPVsReleasesStaleClaims(t *testing.T) {
+	ctx := context.Background()
+	config := StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: "pvcbench-dev",
+		Replicas:  2,
+		PVCSize:   "100Mi",
+		StaticPVs: &StaticPVOptions{Type: StaticPVHostPath, Path: "/tmp/pvcbench", Workers: 2},
+	}
+	claimRef := func(ordinal int, uid types.UID) *corev1.ObjectReference {
+		return &corev1.ObjectReference{Namespace: config.Namespace, Name: PVCName(config.Name, ordinal), UID: uid, ResourceVersion: "7"}
+	}
+	// Ordinal 0 was left Released by the previous run; ordinal 1 is still
+	// bound to a reused PVC.
+	released := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: StaticPVName(config.Namespace, config.Name, 0)},
+		Spec:       corev1.PersistentVolumeSpec{ClaimRef: claimRef(0, "old-uid")},
+		Status:     corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased},
+	}
+	bound := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: StaticPVName(config.Namespace, config.Name, 1)},
+		Spec:       corev1.PersistentVolumeSpec{ClaimRef: claimRef(1, "live-uid")},
+		Status:     corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: PVCName(config.Name, 1), Namespace: config.Namespace, UID: "live-uid"}}
+	client := fake.NewSimpleClientset(released, bound, pvc)
+
+	if err := ProvisionStaticPVs(ctx, client, config); err != nil {
+		t.Fatalf("ProvisionStaticPVs error: %v", err)
+	}
+	got, err := client.CoreV1().PersistentVolumes().Get(ctx, released.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pv: %v", err)
+	}
+	if got.Spec.ClaimRef == nil || got.Spec.ClaimRef.UID != "" || got.Spec.ClaimRef.ResourceVersion != "" || got.Spec.ClaimRef.Name != PVCName(config.Name, 0) {
+		t.Fatalf("expected the stale claim uid to be dropped, got %+v", got.Spec.ClaimRef)
+	}
+	got, err = client.CoreV1().PersistentVolumes().Get(ctx, bound.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pv: %v", err)
+	}
+	if got.Spec.ClaimRef.UID != "live-uid" {
+		t.Fatalf("expected the live binding to be kept, got %+v", got.Spec.ClaimRef)
+	}
+}
+
+func TestDeleteStaticPVs(t *testing.T) {
+	ctx := context.Background()
+	config := StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: "pvcbench-dev",
+		Replicas:  3,
+		StaticPVs: &StaticPVOptions{Type: StaticPVHostPath, Workers: 2},
+	}
+	other := &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: StaticPVName("pvcbench-other", config.Name, 0)}}
+	client := fake.NewSimpleClientset(
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: StaticPVName(config.Namespace, config.Name, 0)}},
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: StaticPVName(config.Namespace, config.Name, 2)}},
+		other,
+	)
+
+	if err := DeleteStaticPVs(ctx, client, config); err != nil {
+		t.Fatalf("DeleteStaticPVs error: %v", err)
+	}
+	pvs, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("list pvs: %v", err)
+	}
+	if len(pvs.Items) != 1 || pvs.Items[0].Name != other.Name {
+		t.Fatalf("expected only the other run's pv to remain, got %v", pvs.Items)
+	}
+}
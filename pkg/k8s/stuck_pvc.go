@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"pvc-protection-bench/pkg/logging"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	StuckPolicyFail    = "fail"
+	StuckPolicyExclude = "exclude"
+)
+
+type PVDiagnosis struct {
+	Name          string
+	Phase         corev1.PersistentVolumePhase
+	ReclaimPolicy corev1.PersistentVolumeReclaimPolicy
+	Finalizers    []string
+}
+
+type StuckPVCReport struct {
+	Name              string
+	StuckFor          time.Duration
+	Finalizers        []string
+	DeletionTimestamp *time.Time
+	// Pods lists pods in the namespace that still mount the PVC.
+	Pods   []string
+	Events []string
+	// PV is nil when the claim is not bound or the volume could not be read.
+	PV *PVDiagnosis
+}
+
+type StuckPVCError struct {
+	Threshold time.Duration
+	Reports   []StuckPVCReport
+}
+
+func (e *StuckPVCError) Error() string {
+	names := make([]string, 0, len(e.Reports))
+	for _, r := range e.Reports {
+		names = append(names, r.Name)
+	}
+	return fmt.Sprintf("%d PVCs not deleted within %s: %s", len(e.Reports), e.Threshold, strings.Join(names, ", "))
+}
+
+func (r StuckPVCReport) Log(logger *zap.Logger) {
+	deletionTimestamp := "<none>"
+	if r.DeletionTimestamp != nil {
+		deletionTimestamp = r.DeletionTimestamp.Format(time.RFC3339)
+	}
+	pv := "<unbound>"
+	if r.PV != nil {
+		pv = fmt.Sprintf("%s phase=%s reclaim=%s finalizers=%s", r.PV.Name, r.PV.Phase, r.PV.ReclaimPolicy, strings.Join(r.PV.Finalizers, ","))
+	}
+	logger.Warn("pvc stuck",
+		logging.StringField("pvc", r.Name),
+		logging.StringField("stuck_for", r.StuckFor.Round(time.Second).String()),
+		logging.StringField("finalizers", strings.Join(r.Finalizers, ",")),
+		logging.StringField("deletion_timestamp", deletionTimestamp),
+		logging.StringField("pods", strings.Join(r.Pods, ",")),
+		logging.StringField("events", strings.Join(r.Events, " | ")),
+		logging.StringField("pv", pv),
+	)
+}
+
+// ClaimIndex holds, by claim name, the pods mounting a PVC and the events
+// recorded for it. It is built from one List of each per detection pass, so
+// diagnosing many stuck PVCs does not list the namespace once per PVC while
+// deletion is being measured.
+type ClaimIndex struct {
+	Pods   map[string][]string
+	Events map[string][]string
+}
+
+func IndexClaims(ctx context.Context, client kubernetes.Interface, namespace string) (ClaimIndex, error) {
+	index := ClaimIndex{Pods: map[string][]string{}, Events: map[string][]string{}}
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return index, fmt.Errorf("failed to list pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				index.Pods[v.PersistentVolumeClaim.ClaimName] = append(index.Pods[v.PersistentVolumeClaim.ClaimName], pod.Name)
+			}
+		}
+	}
+
+	events, err := client.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=PersistentVolumeClaim",
+	})
+	if err != nil {
+		return index, fmt.Errorf("failed to list events: %v", err)
+	}
+	for _, e := range events.Items {
+		if e.InvolvedObject.Kind == "PersistentVolumeClaim" {
+			index.Events[e.InvolvedObject.Name] = append(index.Events[e.InvolvedObject.Name], fmt.Sprintf("%s: %s", e.Reason, e.Message))
+		}
+	}
+	return index, nil
+}
+
+// DiagnoseStuckPVC collects what is keeping pvc from being removed: its
+// finalizers, the pods that still reference it and its events from claims,
+// and its bound PV.
+func DiagnoseStuckPVC(ctx context.Context, client kubernetes.Interface, claims ClaimIndex, pvc *corev1.PersistentVolumeClaim, stuckFor time.Duration) (StuckPVCReport, error) {
+	report := StuckPVCReport{
+		Name:       pvc.Name,
+		StuckFor:   stuckFor,
+		Finalizers: pvc.Finalizers,
+	}
+	if pvc.DeletionTimestamp != nil {
+		ts := pvc.DeletionTimestamp.Time
+		report.DeletionTimestamp = &ts
+	}
+
+	report.Pods = claims.Pods[pvc.Name]
+	report.Events = claims.Events[pvc.Name]
+
+	if pvc.Spec.VolumeName != "" {
+		pv, err := client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return report, fmt.Errorf("failed to get pv %s: %v", pvc.Spec.VolumeName, err)
+		}
+		if err == nil {
+			report.PV = &PVDiagnosis{
+				Name:          pv.Name,
+				Phase:         pv.Status.Phase,
+				ReclaimPolicy: pv.Spec.PersistentVolumeReclaimPolicy,
+				Finalizers:    pv.Finalizers,
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,143 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func stuckPVCObjects(namespace string) []runtime.Object {
+	deletedAt := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	return []runtime.Object{
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "data-pvcbench-sts-0",
+				Namespace:         namespace,
+				DeletionTimestamp: &deletedAt,
+				Finalizers:        []string{"kubernetes.io/pvc-protection"},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv-0"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-sts-0", Namespace: namespace},
+			Spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data-pvcbench-sts-0"},
+					},
+				}},
+			},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "ev-1", Namespace: namespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "data-pvcbench-sts-0"},
+			Reason:         "FailedDelete",
+			Message:        "in use",
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-0", Finalizers: []string{"kubernetes.io/pv-protection"}},
+			Spec:       corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete},
+			Status:     corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+		},
+	}
+}
+
+func TestDiagnoseStuckPVC(t *testing.T) {
+	client := fake.NewSimpleClientset(stuckPVCObjects("test-ns")...)
+	ctx := context.Background()
+
+	pvc, err := client.CoreV1().PersistentVolumeClaims("test-ns").Get(ctx, "data-pvcbench-sts-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pvc: %v", err)
+	}
+	claims, err := IndexClaims(ctx, client, "test-ns")
+	if err != nil {
+		t.Fatalf("IndexClaims error: %v", err)
+	}
+	report, err := DiagnoseStuckPVC(ctx, client, claims, pvc, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("DiagnoseStuckPVC error: %v", err)
+	}
+	if len(report.Finalizers) != 1 || report.Finalizers[0] != "kubernetes.io/pvc-protection" {
+		t.Fatalf("unexpected finalizers: %v", report.Finalizers)
+	}
+	if report.DeletionTimestamp == nil {
+		t.Fatalf("expected deletion timestamp")
+	}
+	if len(report.Pods) != 1 || report.Pods[0] != "pvcbench-sts-0" {
+		t.Fatalf("unexpected pods: %v", report.Pods)
+	}
+	if len(report.Events) != 1 || report.Events[0] != "FailedDelete: in use" {
+		t.Fatalf("unexpected events: %v", report.Events)
+	}
+	if report.PV == nil || report.PV.Name != "pv-0" || report.PV.Phase != corev1.VolumeBound {
+		t.Fatalf("unexpected pv diagnosis: %+v", report.PV)
+	}
+}
+
+func TestPollPVCDeletionStuckPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	opts := PollOptions{Interval: time.Millisecond, StuckThreshold: time.Minute}
+
+	opts.StuckPolicy = StuckPolicyFail
+	client := fake.NewSimpleClientset(stuckPVCObjects("test-ns")...)
+	_, stuck, err := PollPVCDeletion(ctx, client, "test-ns", []string{"data-pvcbench-sts-0"}, opts)
+	var stuckErr *StuckPVCError
+	if !errors.As(err, &stuckErr) {
+		t.Fatalf("expected StuckPVCError, got %v", err)
+	}
+	if len(stuck) != 1 || len(stuckErr.Reports) != 1 {
+		t.Fatalf("expected 1 stuck report, got %d", len(stuck))
+	}
+
+	opts.StuckPolicy = StuckPolicyExclude
+	client = fake.NewSimpleClientset(stuckPVCObjects("test-ns")...)
+	samples, stuck, err := PollPVCDeletion(ctx, client, "test-ns", []string{"data-pvcbench-sts-0"}, opts)
+	if err != nil {
+		t.Fatalf("expected stuck pvc to be excluded, got %v", err)
+	}
+	if len(samples) != 0 || len(stuck) != 1 {
+		t.Fatalf("expected 0 samples and 1 excluded pvc, got %d and %d", len(samples), len(stuck))
+	}
+}
+
+func TestPollPVCDeletionListsOncePerPass(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	objects := stuckPVCObjects("test-ns")
+	names := []string{"data-pvcbench-sts-0"}
+	deletedAt := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	for _, name := range []string{"data-pvcbench-sts-1", "data-pvcbench-sts-2"} {
+		objects = append(objects, &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", DeletionTimestamp: &deletedAt, Finalizers: []string{"kubernetes.io/pvc-protection"}},
+		})
+		names = append(names, name)
+	}
+	client := fake.NewSimpleClientset(objects...)
+
+	_, stuck, err := PollPVCDeletion(ctx, client, "test-ns", names, PollOptions{Interval: time.Millisecond, StuckThreshold: time.Minute, StuckPolicy: StuckPolicyExclude})
+	if err != nil {
+		t.Fatalf("PollPVCDeletion error: %v", err)
+	}
+	if len(stuck) != 3 || len(stuck[0].Pods) != 1 || len(stuck[0].Events) != 1 {
+		t.Fatalf("expected 3 diagnosed pvcs with the pod and event of the first, got %+v", stuck)
+	}
+	lists := map[string]int{}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" {
+			lists[action.GetResource().Resource]++
+		}
+	}
+	if lists["pods"] != 1 || lists["events"] != 1 {
+		t.Fatalf("expected one pod and one event list for the pass, got %v", lists)
+	}
+}
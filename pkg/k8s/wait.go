@@ -2,36 +2,124 @@ package k8s
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	"k8s.io/apimachinery/pkg/api/errors"
+	"pvc-protection-bench/pkg/logging"
+	"pvc-protection-bench/pkg/metrics"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 )
 
-func WaitForStatefulSetReady(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
-	return wait.PollImmediate(1*time.Second, 10*time.Minute, func() (bool, error) {
-		sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
-		if err != nil {
-			return false, err
+const (
+	waitPollInterval      = 1 * time.Second
+	readyProgressInterval = 10 * time.Second
+)
+
+// PhaseTimeoutError reports which phase of a run ran out of time and the last
+// state observed before it did, e.g. "ready phase timed out: 87/100 ready after 10m0s".
+type PhaseTimeoutError struct {
+	Phase   string
+	State   string
+	Elapsed time.Duration
+}
+
+func (e *PhaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s phase timed out: %s after %s", e.Phase, e.State, e.Elapsed.Round(time.Second))
+}
+
+// pollUntil polls condition until it reports done. A timeout <= 0 leaves the
+// deadline to ctx. Hitting either deadline yields a PhaseTimeoutError carrying
+// the last state returned by condition; cancellation is returned wrapped.
+func pollUntil(ctx context.Context, phase string, timeout time.Duration, condition func(ctx context.Context) (bool, string, error)) error {
+	pollCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	state := "no state observed"
+	err := wait.PollUntilContextCancel(pollCtx, waitPollInterval, true, func(ctx context.Context) (bool, error) {
+		done, s, err := condition(ctx)
+		if s != "" {
+			state = s
 		}
-		if sts.Status.ReadyReplicas == *sts.Spec.Replicas {
-			return true, nil
+		return done, err
+	})
+	if err == nil {
+		return nil
+	}
+	if errors.Is(pollCtx.Err(), context.DeadlineExceeded) {
+		return &PhaseTimeoutError{Phase: phase, State: state, Elapsed: time.Since(start)}
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s phase interrupted (%s): %w", phase, state, ctx.Err())
+	}
+	return err
+}
+
+func WaitForStatefulSetReady(ctx context.Context, client kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	return WaitForStatefulSetsReady(ctx, client, namespace, []string{name}, timeout)
+}
+
+// WaitForStatefulSetsReady waits until every replica of the named
+// StatefulSets is ready. Each poll reads all of them, so the progress gauges
+// and log show the totals across shards.
+func WaitForStatefulSetsReady(ctx context.Context, client kubernetes.Interface, namespace string, names []string, timeout time.Duration) error {
+	logger := logging.GetLogger().With(
+		logging.StringField("namespace", namespace),
+		logging.StringField("name", strings.Join(names, ",")),
+	)
+	lastProgress := time.Now()
+	defer func() {
+		metrics.StatefulSetReadyReplicas.Set(0)
+		metrics.StatefulSetCreatedReplicas.Set(0)
+	}()
+
+	return pollUntil(ctx, "ready", timeout, func(ctx context.Context) (bool, string, error) {
+		var ready, created, desired int32
+		for _, name := range names {
+			sts, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, "", err
+			}
+			ready += sts.Status.ReadyReplicas
+			created += sts.Status.Replicas
+			desired += *sts.Spec.Replicas
+		}
+		metrics.StatefulSetReadyReplicas.Set(float64(ready))
+		metrics.StatefulSetCreatedReplicas.Set(float64(created))
+
+		state := fmt.Sprintf("%d/%d ready", ready, desired)
+		if time.Since(lastProgress) >= readyProgressInterval {
+			lastProgress = time.Now()
+			logger.Info("waiting for pods to be ready",
+				logging.StringField("ready", fmt.Sprintf("%d/%d", ready, desired)),
+				logging.StringField("created", fmt.Sprintf("%d", created)),
+			)
 		}
-		return false, nil
+		return ready == desired, state, nil
 	})
 }
 
-func WaitForStatefulSetDeleted(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
-	return wait.PollImmediate(1*time.Second, 10*time.Minute, func() (bool, error) {
+// WaitForStatefulSetDeleted is used while setting up a run, so its timeouts
+// are reported against the setup phase.
+func WaitForStatefulSetDeleted(ctx context.Context, client kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	return pollUntil(ctx, "setup", timeout, func(ctx context.Context) (bool, string, error) {
 		_, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
 		if err == nil {
-			return false, nil
+			return false, fmt.Sprintf("statefulset %s still present", name), nil
 		}
-		if errors.IsNotFound(err) {
-			return true, nil
+		if apierrors.IsNotFound(err) {
+			return true, "", nil
 		}
-		return false, err
+		return false, "", err
 	})
 }
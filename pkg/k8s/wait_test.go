@@ -2,14 +2,22 @@ package k8s
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
+
+	"pvc-protection-bench/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestWaitForStatefulSetDeleted(t *testing.T) {
@@ -21,7 +29,79 @@ func TestWaitForStatefulSetDeleted(t *testing.T) {
 		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "statefulsets"}, "pvcbench-sts")
 	})
 
-	if err := WaitForStatefulSetDeleted(ctx, client, "pvcbench-ns", "pvcbench-sts"); err != nil {
+	if err := WaitForStatefulSetDeleted(ctx, client, "pvcbench-ns", "pvcbench-sts", time.Second); err != nil {
 		t.Fatalf("WaitForStatefulSetDeleted error: %v", err)
 	}
 }
+
+func TestWaitForStatefulSetReadyTimeoutNamesPhaseAndState(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+
+	replicas := int32(3)
+	_, err := client.AppsV1().StatefulSets("pvcbench-ns").Create(ctx, &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-sts", Namespace: "pvcbench-ns"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("create statefulset: %v", err)
+	}
+
+	err = WaitForStatefulSetReady(ctx, client, "pvcbench-ns", "pvcbench-sts", 50*time.Millisecond)
+	var timeoutErr *PhaseTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected PhaseTimeoutError, got %v", err)
+	}
+	if timeoutErr.Phase != "ready" || !strings.Contains(err.Error(), "1/3 ready") {
+		t.Fatalf("unexpected timeout error: %v", err)
+	}
+}
+
+func TestWaitForStatefulSetsReadySumsShards(t *testing.T) {
+	replicas := int32(2)
+	shard := func(name string, ready int32) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "pvcbench-ns"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+			Status:     appsv1.StatefulSetStatus{Replicas: 2, ReadyReplicas: ready},
+		}
+	}
+	client := fake.NewSimpleClientset(shard("pvcbench-sts-0", 2), shard("pvcbench-sts-1", 1))
+	var ready, created float64
+	client.PrependReactor("get", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.GetAction).GetName() == "pvcbench-sts-1" {
+			ready, created = testutil.ToFloat64(metrics.StatefulSetReadyReplicas), testutil.ToFloat64(metrics.StatefulSetCreatedReplicas)
+		}
+		return false, nil, nil
+	})
+
+	err := WaitForStatefulSetsReady(context.Background(), client, "pvcbench-ns", []string{"pvcbench-sts-0", "pvcbench-sts-1"}, 1500*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "3/4 ready") {
+		t.Fatalf("expected the state to sum both shards, got %v", err)
+	}
+	// The gauges are set after every shard has been read; the second poll
+	// sees the totals of the first.
+	if ready != 3 || created != 4 {
+		t.Fatalf("expected gauges to show 3 ready and 4 created across shards, got %v and %v", ready, created)
+	}
+	if testutil.ToFloat64(metrics.StatefulSetReadyReplicas) != 0 {
+		t.Fatalf("expected the ready gauge to be reset after the wait")
+	}
+}
+
+func TestWaitForStatefulSetReadyHonorsCancellation(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	client.PrependReactor("get", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		cancel()
+		replicas := int32(2)
+		return true, &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: &replicas}}, nil
+	})
+
+	err := WaitForStatefulSetReady(ctx, client, "pvcbench-ns", "pvcbench-sts", time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -22,6 +22,18 @@ var (
 		Buckets: prometheus.DefBuckets,
 	}, []string{"scenario", "pvc_size", "replicas", "ns_group"})
 
+	PVCBatchDeleteLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pvcbench_pvc_batch_delete_latency_seconds",
+		Help:    "Latency from PVC deletion timestamp to actual deletion, by scale-down batch; later batches share one \"N+\" series",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"scenario", "batch"})
+
+	PVDeleteLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pvcbench_pv_delete_latency_seconds",
+		Help:    "Latency from PVC deletion to deletion of the PersistentVolume bound to it",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"scenario", "pvc_size"})
+
 	ErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "pvcbench_errors_total",
 		Help: "Total number of errors during benchmark",
@@ -36,6 +48,16 @@ var (
 		Name: "pvcbench_progress_pvcs_terminating",
 		Help: "Number of PVCs currently in terminating state",
 	})
+
+	StatefulSetReadyReplicas = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pvcbench_progress_ready_replicas",
+		Help: "Number of ready replicas while waiting for the StatefulSet to become ready",
+	})
+
+	StatefulSetCreatedReplicas = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pvcbench_progress_created_replicas",
+		Help: "Number of created replicas while waiting for the StatefulSet to become ready",
+	})
 )
 
 var Registry = prometheus.NewRegistry()
@@ -44,7 +66,11 @@ func init() {
 	Registry.MustRegister(RunInfo)
 	Registry.MustRegister(TotalDuration)
 	Registry.MustRegister(PVCDeleteLatency)
+	Registry.MustRegister(PVCBatchDeleteLatency)
+	Registry.MustRegister(PVDeleteLatency)
 	Registry.MustRegister(ErrorsTotal)
 	Registry.MustRegister(PodsRemaining)
 	Registry.MustRegister(PVCsTerminating)
+	Registry.MustRegister(StatefulSetReadyReplicas)
+	Registry.MustRegister(StatefulSetCreatedReplicas)
 }
@@ -0,0 +1,156 @@
+package saturation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"pvc-protection-bench/pkg/stats"
+)
+
+const (
+	ModeBinary = "binary"
+	ModeRamp   = "ramp"
+)
+
+type Options struct {
+	Mode        string
+	MinReplicas int32
+	MaxReplicas int32
+	// Step is the ramp increment, and the resolution at which binary search
+	// stops narrowing the interval.
+	Step      int32
+	Trials    int
+	TargetP99 time.Duration
+}
+
+// TrialFunc runs a single measurement at the given load and returns its p99.
+type TrialFunc func(ctx context.Context, replicas int32, trial int) (time.Duration, error)
+
+type Point struct {
+	Replicas int32
+	TrialP99 []time.Duration
+	// P99 is the median of the per-trial p99 values.
+	P99    time.Duration
+	Passed bool
+}
+
+type Report struct {
+	Points []Point
+	// Knee is the largest measured load that stayed within the target, or 0
+	// if even the minimum load exceeded it.
+	Knee int32
+}
+
+func (o Options) Validate() error {
+	if o.Mode != ModeBinary && o.Mode != ModeRamp {
+		return fmt.Errorf("unknown saturation mode: %s", o.Mode)
+	}
+	if o.MinReplicas <= 0 {
+		return fmt.Errorf("min-replicas must be > 0 (got %d)", o.MinReplicas)
+	}
+	if o.MaxReplicas < o.MinReplicas {
+		return fmt.Errorf("max-replicas must be >= min-replicas (got %d, min=%d)", o.MaxReplicas, o.MinReplicas)
+	}
+	if o.Step <= 0 {
+		return fmt.Errorf("step must be > 0 (got %d)", o.Step)
+	}
+	if o.Trials <= 0 {
+		return fmt.Errorf("trials must be > 0 (got %d)", o.Trials)
+	}
+	if o.TargetP99 <= 0 {
+		return fmt.Errorf("target-p99 must be > 0 (got %s)", o.TargetP99)
+	}
+	return nil
+}
+
+func Search(ctx context.Context, opts Options, trial TrialFunc) (Report, error) {
+	if err := opts.Validate(); err != nil {
+		return Report{}, err
+	}
+
+	s := &searcher{opts: opts, trial: trial}
+	var err error
+	switch opts.Mode {
+	case ModeRamp:
+		err = s.ramp(ctx)
+	case ModeBinary:
+		err = s.binary(ctx)
+	}
+
+	sort.Slice(s.report.Points, func(i, j int) bool {
+		return s.report.Points[i].Replicas < s.report.Points[j].Replicas
+	})
+	return s.report, err
+}
+
+type searcher struct {
+	opts   Options
+	trial  TrialFunc
+	report Report
+}
+
+// ramp steps up from MinReplicas, clamping the last step to MaxReplicas so
+// that the maximum is measured even when Step does not divide the range.
+func (s *searcher) ramp(ctx context.Context) error {
+	replicas := s.opts.MinReplicas
+	for {
+		passed, err := s.measure(ctx, replicas)
+		if err != nil || !passed || replicas == s.opts.MaxReplicas {
+			return err
+		}
+		replicas = min(replicas+s.opts.Step, s.opts.MaxReplicas)
+	}
+}
+
+func (s *searcher) binary(ctx context.Context) error {
+	passed, err := s.measure(ctx, s.opts.MinReplicas)
+	if err != nil || !passed {
+		return err
+	}
+	if s.opts.MaxReplicas == s.opts.MinReplicas {
+		return nil
+	}
+	passed, err = s.measure(ctx, s.opts.MaxReplicas)
+	if err != nil || passed {
+		return err
+	}
+
+	lo, hi := s.opts.MinReplicas, s.opts.MaxReplicas
+	for hi-lo > s.opts.Step {
+		mid := lo + (hi-lo)/2
+		passed, err := s.measure(ctx, mid)
+		if err != nil {
+			return err
+		}
+		if passed {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return nil
+}
+
+func (s *searcher) measure(ctx context.Context, replicas int32) (bool, error) {
+	point := Point{Replicas: replicas}
+	for i := 0; i < s.opts.Trials; i++ {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		p99, err := s.trial(ctx, replicas, i)
+		if err != nil {
+			return false, fmt.Errorf("trial %d at %d replicas: %w", i+1, replicas, err)
+		}
+		point.TrialP99 = append(point.TrialP99, p99)
+	}
+	point.P99 = stats.Percentile(stats.Sorted(point.TrialP99), 50)
+	point.Passed = point.P99 <= s.opts.TargetP99
+
+	s.report.Points = append(s.report.Points, point)
+	if point.Passed && replicas > s.report.Knee {
+		s.report.Knee = replicas
+	}
+	return point.Passed, nil
+}
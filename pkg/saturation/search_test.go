@@ -0,0 +1,146 @@
+package saturation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// linearTrial models a cluster whose p99 grows by 100ms per replica.
+func linearTrial(calls *[]int32) TrialFunc {
+	return func(ctx context.Context, replicas int32, trial int) (time.Duration, error) {
+		*calls = append(*calls, replicas)
+		return time.Duration(replicas) * 100 * time.Millisecond, nil
+	}
+}
+
+func TestSearchBinaryFindsKnee(t *testing.T) {
+	var calls []int32
+	report, err := Search(context.Background(), Options{
+		Mode:        ModeBinary,
+		MinReplicas: 10,
+		MaxReplicas: 200,
+		Step:        5,
+		Trials:      1,
+		TargetP99:   5 * time.Second,
+	}, linearTrial(&calls))
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if report.Knee < 45 || report.Knee > 50 {
+		t.Fatalf("expected knee within one step of 50, got %d", report.Knee)
+	}
+	if len(calls) > 10 {
+		t.Fatalf("expected binary search to converge quickly, made %d trials", len(calls))
+	}
+	for i := 1; i < len(report.Points); i++ {
+		if report.Points[i-1].Replicas > report.Points[i].Replicas {
+			t.Fatalf("expected curve sorted by replicas")
+		}
+	}
+}
+
+func TestSearchRampStopsAtFirstFailure(t *testing.T) {
+	var calls []int32
+	report, err := Search(context.Background(), Options{
+		Mode:        ModeRamp,
+		MinReplicas: 10,
+		MaxReplicas: 100,
+		Step:        20,
+		Trials:      2,
+		TargetP99:   4 * time.Second,
+	}, linearTrial(&calls))
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if report.Knee != 30 {
+		t.Fatalf("expected knee 30, got %d", report.Knee)
+	}
+	if len(report.Points) != 3 || report.Points[2].Passed {
+		t.Fatalf("expected ramp to stop after first failing point, got %+v", report.Points)
+	}
+	if len(calls) != 6 {
+		t.Fatalf("expected 2 trials per point, got %d calls", len(calls))
+	}
+}
+
+func TestSearchMinimumFails(t *testing.T) {
+	var calls []int32
+	report, err := Search(context.Background(), Options{
+		Mode:        ModeBinary,
+		MinReplicas: 100,
+		MaxReplicas: 200,
+		Step:        10,
+		Trials:      1,
+		TargetP99:   time.Second,
+	}, linearTrial(&calls))
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if report.Knee != 0 || len(calls) != 1 {
+		t.Fatalf("expected no knee after a single failing trial, got knee=%d calls=%d", report.Knee, len(calls))
+	}
+}
+
+func TestSearchPropagatesTrialError(t *testing.T) {
+	_, err := Search(context.Background(), Options{
+		Mode:        ModeRamp,
+		MinReplicas: 10,
+		MaxReplicas: 20,
+		Step:        10,
+		Trials:      1,
+		TargetP99:   time.Second,
+	}, func(ctx context.Context, replicas int32, trial int) (time.Duration, error) {
+		return 0, fmt.Errorf("boom")
+	})
+	if err == nil {
+		t.Fatalf("expected trial error")
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	valid := Options{Mode: ModeBinary, MinReplicas: 1, MaxReplicas: 2, Step: 1, Trials: 1, TargetP99: time.Second}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid options: %v", err)
+	}
+	for name, mutate := range map[string]func(*Options){
+		"mode":   func(o *Options) { o.Mode = "linear" },
+		"min":    func(o *Options) { o.MinReplicas = 0 },
+		"max":    func(o *Options) { o.MaxReplicas = 0 },
+		"step":   func(o *Options) { o.Step = 0 },
+		"trials": func(o *Options) { o.Trials = 0 },
+		"target": func(o *Options) { o.TargetP99 = 0 },
+	} {
+		o := valid
+		mutate(&o)
+		if err := o.Validate(); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestSearchRampMeasuresMaximum(t *testing.T) {
+	var calls []int32
+	report, err := Search(context.Background(), Options{
+		Mode:        ModeRamp,
+		MinReplicas: 10,
+		MaxReplicas: 100,
+		Step:        20,
+		Trials:      3,
+		TargetP99:   time.Minute,
+	}, linearTrial(&calls))
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if report.Knee != 100 {
+		t.Fatalf("expected the clamped last step to reach 100, got knee %d", report.Knee)
+	}
+	last := report.Points[len(report.Points)-1]
+	if len(report.Points) != 6 || last.Replicas != 100 {
+		t.Fatalf("expected 10, 30, 50, 70, 90 and 100 to be measured, got %+v", report.Points)
+	}
+	if last.P99 != 10*time.Second {
+		t.Fatalf("expected the median of the trial p99s, got %s", last.P99)
+	}
+}
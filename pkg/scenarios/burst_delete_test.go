@@ -2,6 +2,8 @@ package scenarios
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -9,6 +11,7 @@ import (
 	"pvc-protection-bench/pkg/k8s"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -18,8 +21,41 @@ import (
 	k8stesting "k8s.io/client-go/testing"
 )
 
+// fakeApply makes server-side applies of StatefulSets and their scale
+// subresource work against the fake clientset, whose tracker supports
+// neither.
+func fakeApply(client *fake.Clientset) {
+	gvr := appsv1.SchemeGroupVersion.WithResource("statefulsets")
+	client.PrependReactor("patch", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetSubresource() == "scale" {
+			scale := &autoscalingv1.Scale{}
+			if err := json.Unmarshal(patch.GetPatch(), scale); err != nil {
+				return true, nil, err
+			}
+			obj, err := client.Tracker().Get(gvr, patch.GetNamespace(), patch.GetName())
+			if err != nil {
+				return true, nil, err
+			}
+			sts := obj.(*appsv1.StatefulSet).DeepCopy()
+			sts.Spec.Replicas = &scale.Spec.Replicas
+			return true, scale, client.Tracker().Update(gvr, sts, patch.GetNamespace())
+		}
+		sts := &appsv1.StatefulSet{}
+		if err := json.Unmarshal(patch.GetPatch(), sts); err != nil {
+			return true, nil, err
+		}
+		err := client.Tracker().Create(gvr, sts, patch.GetNamespace())
+		if apierrors.IsAlreadyExists(err) {
+			err = client.Tracker().Update(gvr, sts, patch.GetNamespace())
+		}
+		return true, sts, err
+	})
+}
+
 func TestRunBurstDelete(t *testing.T) {
 	client := fake.NewSimpleClientset()
+	fakeApply(client)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -87,11 +123,130 @@ func TestRunBurstDelete(t *testing.T) {
 		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "persistentvolumeclaims"}, name)
 	})
 
-	_, latencies, err := RunBurstDelete(ctx, client, config, 1*time.Millisecond)
+	result, err := RunBurstDelete(ctx, client, config, RunOptions{PollInterval: 1 * time.Millisecond})
 	if err != nil {
 		t.Fatalf("RunBurstDelete error: %v", err)
 	}
-	if len(latencies) != int(config.Replicas) {
-		t.Fatalf("expected %d latencies, got %d", config.Replicas, len(latencies))
+	if len(result.Latencies()) != int(config.Replicas) {
+		t.Fatalf("expected %d latencies, got %d", config.Replicas, len(result.Latencies()))
+	}
+}
+
+func TestRunBurstDeleteDrainTimeout(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	fakeApply(client)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	config := k8s.StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: "pvcbench-test",
+		Replicas:  2,
+		PVCSize:   "100Mi",
+	}
+
+	client.PrependReactor("get", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := client.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("statefulsets"), get.GetNamespace(), get.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		sts := obj.(*appsv1.StatefulSet).DeepCopy()
+		if sts.Spec.Replicas != nil {
+			sts.Status.ReadyReplicas = *sts.Spec.Replicas
+		}
+		return true, sts, nil
+	})
+
+	// PVCs are never deleted, so the drain phase must time out.
+	for i := 0; i < int(config.Replicas); i++ {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("pvc-%d", i),
+				Namespace: config.Namespace,
+				Labels: map[string]string{
+					"app": config.Name,
+				},
+			},
+		}
+		if _, err := client.CoreV1().PersistentVolumeClaims(config.Namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("create pvc: %v", err)
+		}
+	}
+
+	_, err := RunBurstDelete(ctx, client, config, RunOptions{
+		PollInterval: 1 * time.Millisecond,
+		Timeouts:     Timeouts{Drain: 50 * time.Millisecond},
+	})
+	var timeoutErr *k8s.PhaseTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected PhaseTimeoutError, got %v", err)
+	}
+	if timeoutErr.Phase != "drain" || timeoutErr.State != "0/2 PVCs deleted" {
+		t.Fatalf("unexpected timeout error: %v", err)
+	}
+}
+
+func TestRunBurstDeleteShards(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	fakeApply(client)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	config := k8s.StatefulSetConfig{
+		Name:      "pvcbench-sts",
+		Namespace: "pvcbench-test",
+		Replicas:  4,
+		PVCSize:   "100Mi",
+		Shards:    2,
+	}
+
+	client.PrependReactor("get", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := client.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("statefulsets"), get.GetNamespace(), get.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		sts := obj.(*appsv1.StatefulSet).DeepCopy()
+		if sts.Spec.Replicas != nil {
+			sts.Status.ReadyReplicas = *sts.Spec.Replicas
+		}
+		return true, sts, nil
+	})
+
+	for _, shard := range config.ShardConfigs() {
+		for i := 0; i < int(shard.Replicas); i++ {
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      k8s.PVCName(shard.Name, i),
+					Namespace: config.Namespace,
+					Labels:    map[string]string{"app": shard.Name},
+				},
+			}
+			if _, err := client.CoreV1().PersistentVolumeClaims(config.Namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+				t.Fatalf("create pvc: %v", err)
+			}
+		}
+	}
+	client.PrependReactor("get", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "persistentvolumeclaims"}, name)
+	})
+
+	result, err := RunBurstDelete(ctx, client, config, RunOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("RunBurstDelete error: %v", err)
+	}
+	if len(result.Samples) != 4 {
+		t.Fatalf("expected samples merged across shards, got %d", len(result.Samples))
+	}
+	for _, shard := range config.ShardConfigs() {
+		obj, err := client.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("statefulsets"), config.Namespace, shard.Name)
+		if err != nil {
+			t.Fatalf("get %s: %v", shard.Name, err)
+		}
+		if replicas := *obj.(*appsv1.StatefulSet).Spec.Replicas; replicas != 0 {
+			t.Fatalf("expected %s scaled to 0, got %d", shard.Name, replicas)
+		}
 	}
 }
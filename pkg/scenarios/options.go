@@ -0,0 +1,63 @@
+package scenarios
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+)
+
+// Timeouts bound the individual phases of a run. A zero value leaves the
+// phase bounded only by the run context.
+type Timeouts struct {
+	// Preflight bounds the wait for the cluster to become quiet before a run.
+	Preflight time.Duration
+	Setup     time.Duration
+	// Ready bounds the wait for the pods of all shards together.
+	Ready time.Duration
+	// ScaleDown bounds each scale-down request, not the intervals between
+	// staggered batches.
+	ScaleDown time.Duration
+	Drain     time.Duration
+	// PVDeletion bounds how long the PVs of deleted PVCs are tracked after
+	// the drain. It does not fail the run.
+	PVDeletion time.Duration
+}
+
+type RunOptions struct {
+	PollInterval time.Duration
+	Timeouts     Timeouts
+	// StuckThreshold and StuckPolicy are passed through to PollPVCDeletion.
+	StuckThreshold time.Duration
+	StuckPolicy    string
+	Retry          k8s.RetryPolicy
+	// Reuse keeps an existing StatefulSet that matches the run configuration
+	// instead of recreating it, scaling it back up when a completed run left
+	// it at 0 replicas.
+	Reuse bool
+}
+
+func phaseContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// phaseError turns a deadline hit inside a phase into a PhaseTimeoutError
+// carrying the given state. Errors that already name a phase, and errors
+// caused by the parent context being cancelled, are returned unchanged.
+func phaseError(ctx, phaseCtx context.Context, phase, state string, start time.Time, err error) error {
+	if err == nil {
+		return nil
+	}
+	var timeoutErr *k8s.PhaseTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return err
+	}
+	if errors.Is(phaseCtx.Err(), context.DeadlineExceeded) && !errors.Is(ctx.Err(), context.Canceled) {
+		return &k8s.PhaseTimeoutError{Phase: phase, State: state, Elapsed: time.Since(start)}
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package scenarios
+
+import (
+	"sort"
+	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/stats"
+)
+
+type Result struct {
+	TotalDuration time.Duration
+	Samples       []k8s.DeletionSample
+	// BatchStarts holds the time each scale-down step was issued, indexed by
+	// batch. Burst runs have a single batch.
+	BatchStarts []time.Time
+	// Excluded lists PVCs that exceeded the stuck threshold and were dropped
+	// from the measurement.
+	Excluded []k8s.StuckPVCReport
+	// PVs accounts for the PVs bound to the deleted PVCs.
+	PVs k8s.PVReport
+}
+
+type BatchSummary struct {
+	Batch       int
+	Count       int
+	P50         time.Duration
+	P99         time.Duration
+	TimeToDrain time.Duration
+}
+
+func (r Result) Latencies() []time.Duration {
+	latencies := make([]time.Duration, 0, len(r.Samples))
+	for _, s := range r.Samples {
+		latencies = append(latencies, s.Latency)
+	}
+	return latencies
+}
+
+// Batches groups samples by batch. TimeToDrain is measured from the scale-down
+// step of the batch to the deletion of its last PVC.
+func (r Result) Batches() []BatchSummary {
+	latencies := map[int][]time.Duration{}
+	lastDeleted := map[int]time.Time{}
+	for _, s := range r.Samples {
+		latencies[s.Batch] = append(latencies[s.Batch], s.Latency)
+		if s.DeletedAt.After(lastDeleted[s.Batch]) {
+			lastDeleted[s.Batch] = s.DeletedAt
+		}
+	}
+
+	summaries := make([]BatchSummary, 0, len(latencies))
+	for batch, values := range latencies {
+		sorted := stats.Sorted(values)
+		summary := BatchSummary{
+			Batch: batch,
+			Count: len(sorted),
+			P50:   stats.Percentile(sorted, 50),
+			P99:   stats.Percentile(sorted, 99),
+		}
+		if batch >= 0 && batch < len(r.BatchStarts) {
+			summary.TimeToDrain = lastDeleted[batch].Sub(r.BatchStarts[batch])
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Batch < summaries[j].Batch
+	})
+	return summaries
+}
+
+// staggeredBatch returns the batch that removes the given ordinal when a
+// StatefulSet of the given size is scaled down batchSize replicas at a time.
+// The StatefulSet controller removes the highest ordinals first.
+func staggeredBatch(replicas, batchSize int32, ordinal int) int {
+	if ordinal < 0 || batchSize <= 0 {
+		return 0
+	}
+	fromTop := int(replicas) - 1 - ordinal
+	if fromTop < 0 {
+		return 0
+	}
+	return fromTop / int(batchSize)
+}
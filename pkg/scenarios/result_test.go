@@ -0,0 +1,52 @@
+package scenarios
+
+import (
+	"testing"
+	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+)
+
+func TestStaggeredBatch(t *testing.T) {
+	tests := []struct {
+		ordinal int
+		want    int
+	}{
+		{ordinal: 9, want: 0},
+		{ordinal: 7, want: 0},
+		{ordinal: 6, want: 1},
+		{ordinal: 0, want: 3},
+		{ordinal: -1, want: 0},
+	}
+	for _, tt := range tests {
+		if got := staggeredBatch(10, 3, tt.ordinal); got != tt.want {
+			t.Fatalf("ordinal %d: expected batch %d, got %d", tt.ordinal, tt.want, got)
+		}
+	}
+}
+
+func TestResultBatches(t *testing.T) {
+	base := time.Now()
+	result := Result{
+		BatchStarts: []time.Time{base, base.Add(5 * time.Second)},
+		Samples: []k8s.DeletionSample{
+			{PVC: "data-pvcbench-sts-3", Batch: 0, Latency: time.Second, DeletedAt: base.Add(2 * time.Second)},
+			{PVC: "data-pvcbench-sts-2", Batch: 0, Latency: 3 * time.Second, DeletedAt: base.Add(4 * time.Second)},
+			{PVC: "data-pvcbench-sts-1", Batch: 1, Latency: 2 * time.Second, DeletedAt: base.Add(8 * time.Second)},
+		},
+	}
+
+	batches := result.Batches()
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(batches))
+	}
+	if batches[0].Count != 2 || batches[0].P50 != 2*time.Second || batches[0].TimeToDrain != 4*time.Second {
+		t.Fatalf("unexpected batch 0 summary: %+v", batches[0])
+	}
+	if batches[1].Count != 1 || batches[1].TimeToDrain != 3*time.Second {
+		t.Fatalf("unexpected batch 1 summary: %+v", batches[1])
+	}
+	if len(result.Latencies()) != 3 {
+		t.Fatalf("expected 3 latencies, got %d", len(result.Latencies()))
+	}
+}
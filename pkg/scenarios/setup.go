@@ -0,0 +1,220 @@
+package scenarios
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/logging"
+	"pvc-protection-bench/pkg/metrics"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const diagnosticsTimeout = 30 * time.Second
+
+// prepareStatefulSet (re)creates the benchmark StatefulSets, one per shard,
+// waits for all of their pods to become ready and returns the names of the
+// PVCs they own and the PVs bound to them, merged across shards.
+func prepareStatefulSet(ctx context.Context, client kubernetes.Interface, config k8s.StatefulSetConfig, opts RunOptions, logger *zap.Logger) ([]string, map[string]string, error) {
+	setupCtx, cancel := phaseContext(ctx, opts.Timeouts.Setup)
+	defer cancel()
+	setupStart := time.Now()
+
+	// 1. Ensure Namespace
+	if err := k8s.EnsureNamespace(setupCtx, client, config.Namespace, config.Run); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("namespace_creation").Inc()
+		return nil, nil, phaseError(ctx, setupCtx, "setup", "creating namespace", setupStart, err)
+	}
+
+	// 2. Create StatefulSets, or reuse matching ready ones
+	shards := config.ShardConfigs()
+	for _, shard := range shards {
+		if err := createShard(ctx, setupCtx, client, shard, opts, setupStart, logger); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 3. Wait for all Pods Ready, under one deadline for all shards
+	readyCtx, cancelReady := phaseContext(ctx, opts.Timeouts.Ready)
+	defer cancelReady()
+	names := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		names = append(names, shard.Name)
+	}
+	logger.Info("waiting for pods to be ready", logging.StringField("name", strings.Join(names, ",")))
+	if err := k8s.WaitForStatefulSetsReady(readyCtx, client, config.Namespace, names, 0); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("sts_ready_wait").Inc()
+		if !errors.Is(err, context.Canceled) {
+			labelSelector := fmt.Sprintf("app in (%s)", strings.Join(names, ","))
+			logReadinessDiagnostics(ctx, client, config.Namespace, labelSelector, logger)
+		}
+		return nil, nil, err
+	}
+
+	// 4. Capture PVC names for GET polling
+	var pvcNames []string
+	pvs := map[string]string{}
+	for _, shard := range shards {
+		labelSelector := fmt.Sprintf("app=%s", shard.Name)
+		names, err := k8s.ListPVCNames(ctx, client, shard.Namespace, labelSelector)
+		if err != nil {
+			metrics.ErrorsTotal.WithLabelValues("pvc_list").Inc()
+			return nil, nil, err
+		}
+		pvcNames = append(pvcNames, names...)
+		bound, err := k8s.BoundPVs(ctx, client, shard.Namespace, labelSelector)
+		if err != nil {
+			metrics.ErrorsTotal.WithLabelValues("pvc_list").Inc()
+			return nil, nil, err
+		}
+		for pvc, pv := range bound {
+			pvs[pvc] = pv
+		}
+	}
+	return pvcNames, pvs, nil
+}
+
+// createShard creates the StatefulSet of one shard, replacing an existing one
+// unless it can be reused.
+func createShard(ctx, setupCtx context.Context, client kubernetes.Interface, shard k8s.StatefulSetConfig, opts RunOptions, setupStart time.Time, logger *zap.Logger) error {
+	existing, err := client.AppsV1().StatefulSets(shard.Namespace).Get(setupCtx, shard.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		metrics.ErrorsTotal.WithLabelValues("sts_get").Inc()
+		return phaseError(ctx, setupCtx, "setup", "looking up existing statefulset", setupStart, err)
+	}
+	if err == nil && existing != nil {
+		if opts.Reuse && k8s.StatefulSetReusable(existing, shard) {
+			return reuseShard(ctx, setupCtx, client, existing, shard, setupStart, logger)
+		}
+		if opts.Reuse {
+			logger.Info("existing statefulset does not match, recreating it", logging.StringField("name", shard.Name))
+		}
+		if err := k8s.DeleteStatefulSet(setupCtx, client, shard.Namespace, shard.Name); err != nil {
+			metrics.ErrorsTotal.WithLabelValues("sts_delete").Inc()
+			return phaseError(ctx, setupCtx, "setup", "deleting existing statefulset", setupStart, err)
+		}
+		if err := k8s.WaitForStatefulSetDeleted(setupCtx, client, shard.Namespace, shard.Name, 0); err != nil {
+			metrics.ErrorsTotal.WithLabelValues("sts_delete_wait").Inc()
+			return err
+		}
+	}
+
+	if shard.StaticPVs != nil {
+		logger.Info("provisioning static pvs", logging.StringField("name", shard.Name), logging.StringField("type", shard.StaticPVs.Type), logging.StringField("replicas", fmt.Sprintf("%d", shard.Replicas)))
+		if err := k8s.ProvisionStaticPVs(setupCtx, client, shard); err != nil {
+			metrics.ErrorsTotal.WithLabelValues("static_pv_creation").Inc()
+			return phaseError(ctx, setupCtx, "setup", "provisioning static pvs", setupStart, err)
+		}
+	}
+	if _, err := k8s.CreateStatefulSet(setupCtx, client, shard); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("sts_creation").Inc()
+		return phaseError(ctx, setupCtx, "setup", "creating statefulset", setupStart, err)
+	}
+	return nil
+}
+
+// reuseShard keeps a matching StatefulSet and the PVCs it still has. One left
+// at 0 replicas by a completed run is scaled back up; the readiness wait
+// follows as for a new one.
+func reuseShard(ctx, setupCtx context.Context, client kubernetes.Interface, existing *appsv1.StatefulSet, shard k8s.StatefulSetConfig, setupStart time.Time, logger *zap.Logger) error {
+	if *existing.Spec.Replicas == shard.Replicas {
+		logger.Info("reusing statefulset", logging.StringField("name", shard.Name))
+		return nil
+	}
+	logger.Info("reusing statefulset, scaling it back up", logging.StringField("name", shard.Name), logging.StringField("replicas", fmt.Sprintf("%d", shard.Replicas)))
+	if shard.StaticPVs != nil {
+		// The scale-down deleted the pre-bound PVCs; recreate them so the
+		// StatefulSet adopts them again.
+		if err := k8s.ProvisionStaticPVs(setupCtx, client, shard); err != nil {
+			metrics.ErrorsTotal.WithLabelValues("static_pv_creation").Inc()
+			return phaseError(ctx, setupCtx, "setup", "provisioning static pvs", setupStart, err)
+		}
+	}
+	if err := k8s.ScaleStatefulSet(setupCtx, client, shard.Namespace, shard.Name, shard.Replicas); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("sts_scale").Inc()
+		return phaseError(ctx, setupCtx, "setup", "scaling up reused statefulset", setupStart, err)
+	}
+	return nil
+}
+
+// scaleShards scales every shard to the given replica count at the same time
+// and returns the first error.
+func scaleShards(ctx context.Context, client kubernetes.Interface, shards []k8s.StatefulSetConfig, replicas int32) error {
+	errs := make(chan error, len(shards))
+	for _, shard := range shards {
+		go func() {
+			errs <- k8s.ScaleStatefulSet(ctx, client, shard.Namespace, shard.Name, replicas)
+		}()
+	}
+	var first error
+	for range shards {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// logReadinessDiagnostics explains a failed readiness wait. It runs on a
+// fresh deadline because the run context may already be exhausted.
+func logReadinessDiagnostics(ctx context.Context, client kubernetes.Interface, namespace, labelSelector string, logger *zap.Logger) {
+	diagCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), diagnosticsTimeout)
+	defer cancel()
+
+	diag, err := k8s.DiagnoseReadiness(diagCtx, client, namespace, labelSelector)
+	if err != nil {
+		logger.Error("failed to collect readiness diagnostics", logging.ErrorField(err))
+		return
+	}
+	diag.Log(logger)
+}
+
+// drainPVCs polls the tracked PVCs until they are gone, bounded by the drain
+// timeout. Samples gathered before an error are returned with it.
+func drainPVCs(ctx context.Context, client kubernetes.Interface, namespace string, pvcNames []string, pollOpts k8s.PollOptions, opts RunOptions) ([]k8s.DeletionSample, []k8s.StuckPVCReport, error) {
+	drainCtx, cancel := phaseContext(ctx, opts.Timeouts.Drain)
+	defer cancel()
+	drainStart := time.Now()
+
+	pollOpts.Interval = opts.PollInterval
+	pollOpts.StuckThreshold = opts.StuckThreshold
+	pollOpts.StuckPolicy = opts.StuckPolicy
+	pollOpts.Retry = opts.Retry
+	samples, stuck, err := k8s.PollPVCDeletion(drainCtx, client, namespace, pvcNames, pollOpts)
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues("pvc_delete_poll").Inc()
+		state := fmt.Sprintf("%d/%d PVCs deleted", len(samples), len(pvcNames))
+		return samples, stuck, phaseError(ctx, drainCtx, "drain", state, drainStart, err)
+	}
+	return samples, stuck, nil
+}
+
+// trackPVs accounts for the PVs of the PVCs deleted during the drain. Failures
+// are logged and leave a partial report, since the PVC measurement is done.
+func trackPVs(ctx context.Context, client kubernetes.Interface, pvs map[string]string, samples []k8s.DeletionSample, trackOpts k8s.PVTrackOptions, opts RunOptions, logger *zap.Logger) k8s.PVReport {
+	deletedAt := make(map[string]time.Time, len(samples))
+	for _, s := range samples {
+		deletedAt[s.PVC] = s.DeletedAt
+	}
+	trackOpts.Interval = opts.PollInterval
+	trackOpts.Timeout = opts.Timeouts.PVDeletion
+	report, err := k8s.WaitForPVDeletion(ctx, client, pvs, deletedAt, trackOpts)
+	if err != nil {
+		logger.Error("failed to track pv deletion", logging.ErrorField(err))
+	}
+	if len(report.Released)+len(report.Remaining) > 0 {
+		logger.Warn("persistent volumes left behind",
+			logging.StringField("released", fmt.Sprintf("%d", len(report.Released))),
+			logging.StringField("remaining", fmt.Sprintf("%d", len(report.Remaining))),
+		)
+	}
+	return report
+}
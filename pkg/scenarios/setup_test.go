@@ -0,0 +1,61 @@
+package scenarios
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"pvc-protection-bench/pkg/k8s"
+	"pvc-protection-bench/pkg/logging"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPrepareStatefulSetReusesScaledDown(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "pvcbench-dev"}})
+	fakeApply(client)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	config := k8s.StatefulSetConfig{Name: "pvcbench-sts", Namespace: "pvcbench-dev", Replicas: 3, PVCSize: "100Mi"}
+	client.PrependReactor("get", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := client.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("statefulsets"), get.GetNamespace(), get.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		sts := obj.(*appsv1.StatefulSet).DeepCopy()
+		sts.Status.ReadyReplicas = *sts.Spec.Replicas
+		return true, sts, nil
+	})
+
+	// A completed earlier run leaves its StatefulSet scaled to 0.
+	if _, err := k8s.CreateStatefulSet(ctx, client, config); err != nil {
+		t.Fatalf("CreateStatefulSet error: %v", err)
+	}
+	if err := k8s.ScaleStatefulSet(ctx, client, config.Namespace, config.Name, 0); err != nil {
+		t.Fatalf("ScaleStatefulSet error: %v", err)
+	}
+	client.ClearActions()
+
+	if _, _, err := prepareStatefulSet(ctx, client, config, RunOptions{Reuse: true}, logging.GetLogger()); err != nil {
+		t.Fatalf("prepareStatefulSet error: %v", err)
+	}
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "delete" {
+			t.Fatalf("expected the statefulset to be reused, got %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+	sts, err := client.AppsV1().StatefulSets(config.Namespace).Get(ctx, config.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get statefulset: %v", err)
+	}
+	if *sts.Spec.Replicas != config.Replicas {
+		t.Fatalf("expected the statefulset to be scaled back up to %d, got %d", config.Replicas, *sts.Spec.Replicas)
+	}
+}
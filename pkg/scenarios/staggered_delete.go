@@ -9,8 +9,6 @@ import (
 	"pvc-protection-bench/pkg/logging"
 	"pvc-protection-bench/pkg/metrics"
 
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -19,7 +17,7 @@ type StaggeredDeleteOptions struct {
 	Interval  time.Duration
 }
 
-func RunStaggeredDelete(ctx context.Context, client kubernetes.Interface, config k8s.StatefulSetConfig, opts StaggeredDeleteOptions, pollInterval time.Duration) (time.Duration, []time.Duration, error) {
+func RunStaggeredDelete(ctx context.Context, client kubernetes.Interface, config k8s.StatefulSetConfig, opts StaggeredDeleteOptions, runOpts RunOptions) (Result, error) {
 	replicaStr := fmt.Sprintf("%d", config.Replicas)
 	metrics.RunInfo.WithLabelValues("staggered", config.PVCSize, replicaStr).Set(1)
 	defer metrics.RunInfo.WithLabelValues("staggered", config.PVCSize, replicaStr).Set(0)
@@ -31,73 +29,54 @@ func RunStaggeredDelete(ctx context.Context, client kubernetes.Interface, config
 
 	logger.Info("starting staggered scenario")
 
-	if err := k8s.EnsureNamespace(ctx, client, config.Namespace); err != nil {
-		metrics.ErrorsTotal.WithLabelValues("namespace_creation").Inc()
-		return 0, nil, err
-	}
-
-	existing, err := client.AppsV1().StatefulSets(config.Namespace).Get(ctx, config.Name, metav1.GetOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		metrics.ErrorsTotal.WithLabelValues("sts_get").Inc()
-		return 0, nil, err
-	}
-	if err == nil && existing != nil {
-		if err := k8s.DeleteStatefulSet(ctx, client, config.Namespace, config.Name); err != nil {
-			metrics.ErrorsTotal.WithLabelValues("sts_delete").Inc()
-			return 0, nil, err
-		}
-		if err := k8s.WaitForStatefulSetDeleted(ctx, client, config.Namespace, config.Name); err != nil {
-			metrics.ErrorsTotal.WithLabelValues("sts_delete_wait").Inc()
-			return 0, nil, err
-		}
-	}
-
-	sts, err := k8s.CreateStatefulSet(ctx, client, config)
+	pvcNames, pvs, err := prepareStatefulSet(ctx, client, config, runOpts, logger)
 	if err != nil {
-		metrics.ErrorsTotal.WithLabelValues("sts_creation").Inc()
-		return 0, nil, err
-	}
-
-	logger.Info("waiting for pods to be ready")
-	if err := k8s.WaitForStatefulSetReady(ctx, client, config.Namespace, sts.Name); err != nil {
-		metrics.ErrorsTotal.WithLabelValues("sts_ready_wait").Inc()
-		return 0, nil, err
-	}
-
-	labelSelector := fmt.Sprintf("app=%s", sts.Name)
-	pvcNames, err := k8s.ListPVCNames(ctx, client, config.Namespace, labelSelector)
-	if err != nil {
-		metrics.ErrorsTotal.WithLabelValues("pvc_list").Inc()
-		return 0, nil, err
+		return Result{}, err
 	}
 
 	logger.Info("scaling down in batches")
 	metrics.PodsRemaining.Set(float64(config.Replicas))
 	start := time.Now()
-
-	currentReplicas := config.Replicas
-	for currentReplicas > 0 {
-		currentReplicas -= opts.BatchSize
-		if currentReplicas < 0 {
-			currentReplicas = 0
-		}
-
-		logger.Info("scaling down", logging.StringField("replicas", fmt.Sprintf("%d", currentReplicas)))
-		if err := k8s.ScaleStatefulSet(ctx, client, config.Namespace, sts.Name, currentReplicas); err != nil {
+	var batchStarts []time.Time
+
+	plan := newStaggeredPlan(config.ShardConfigs(), opts.BatchSize)
+	remaining := config.Replicas
+	for i, step := range plan.steps {
+		logger.Info("scaling down", logging.StringField("name", step.shard.Name), logging.StringField("replicas", fmt.Sprintf("%d", step.replicas)))
+		batchStarts = append(batchStarts, time.Now())
+		// The scale-down timeout bounds each request, not the intervals
+		// between batches, which grow with the replica count.
+		scaleCtx, cancel := phaseContext(ctx, runOpts.Timeouts.ScaleDown)
+		err := k8s.ScaleStatefulSet(scaleCtx, client, config.Namespace, step.shard.Name, step.replicas)
+		cancel()
+		if err != nil {
 			metrics.ErrorsTotal.WithLabelValues("sts_scale").Inc()
-			return 0, nil, err
+			state := fmt.Sprintf("batch %d scaling %s to %d replicas", len(batchStarts)-1, step.shard.Name, step.replicas)
+			return Result{TotalDuration: time.Since(start), BatchStarts: batchStarts}, phaseError(ctx, scaleCtx, "scale-down", state, batchStarts[len(batchStarts)-1], err)
 		}
-		metrics.PodsRemaining.Set(float64(currentReplicas))
-
-		if currentReplicas > 0 {
-			time.Sleep(opts.Interval)
+		remaining -= step.removed
+		metrics.PodsRemaining.Set(float64(remaining))
+
+		if i < len(plan.steps)-1 {
+			select {
+			case <-ctx.Done():
+				state := fmt.Sprintf("%d replicas remaining after %d batches", remaining, len(batchStarts))
+				return Result{TotalDuration: time.Since(start), BatchStarts: batchStarts}, fmt.Errorf("scale-down phase interrupted (%s): %w", state, ctx.Err())
+			case <-time.After(opts.Interval):
+			}
 		}
 	}
 
-	latencies, err := k8s.PollPVCDeletion(ctx, client, config.Namespace, pvcNames, "staggered", config.PVCSize, int(config.Replicas), "single", pollInterval)
+	samples, stuck, err := drainPVCs(ctx, client, config.Namespace, pvcNames, k8s.PollOptions{
+		Scenario: "staggered",
+		PVCSize:  config.PVCSize,
+		Replicas: int(config.Replicas),
+		NSGroup:  "single",
+		BatchOf:  plan.batchOf,
+	}, runOpts)
 	if err != nil {
-		metrics.ErrorsTotal.WithLabelValues("pvc_delete_poll").Inc()
-		return 0, nil, err
+		// Hand back what was measured so an aborted run can still be summarized.
+		return Result{TotalDuration: time.Since(start), Samples: samples, BatchStarts: batchStarts, Excluded: stuck}, err
 	}
 
 	totalDuration := time.Since(start)
@@ -106,5 +85,56 @@ func RunStaggeredDelete(ctx context.Context, client kubernetes.Interface, config
 
 	metrics.TotalDuration.WithLabelValues("staggered", config.PVCSize, replicaStr).Set(totalDuration.Seconds())
 
-	return totalDuration, latencies, nil
+	pvReport := trackPVs(ctx, client, pvs, samples, k8s.PVTrackOptions{Scenario: "staggered", PVCSize: config.PVCSize}, runOpts, logger)
+
+	return Result{TotalDuration: totalDuration, Samples: samples, BatchStarts: batchStarts, Excluded: stuck, PVs: pvReport}, nil
+}
+
+type scaleStep struct {
+	shard    k8s.StatefulSetConfig
+	replicas int32
+	removed  int32
+}
+
+// staggeredPlan scales the shards down one after another, BatchSize
+// replicas per step, so batches are numbered across shards.
+type staggeredPlan struct {
+	shards    []k8s.StatefulSetConfig
+	batchSize int32
+	// offsets holds the number of batches of the shards before each shard.
+	offsets []int
+	steps   []scaleStep
+}
+
+func newStaggeredPlan(shards []k8s.StatefulSetConfig, batchSize int32) staggeredPlan {
+	plan := staggeredPlan{shards: shards, batchSize: batchSize}
+	for _, shard := range shards {
+		plan.offsets = append(plan.offsets, len(plan.steps))
+		current := shard.Replicas
+		for current > 0 {
+			next := current - batchSize
+			if next < 0 {
+				next = 0
+			}
+			plan.steps = append(plan.steps, scaleStep{shard: shard, replicas: next, removed: current - next})
+			current = next
+		}
+	}
+	return plan
+}
+
+// batchOf maps a PVC to the batch that removes it. With a single shard every
+// PVC belongs to it, whatever its name.
+func (p staggeredPlan) batchOf(pvc string) int {
+	ordinal := k8s.PVCOrdinal(pvc)
+	shard := 0
+	if len(p.shards) > 1 {
+		for i, s := range p.shards {
+			if pvc == k8s.PVCName(s.Name, ordinal) {
+				shard = i
+				break
+			}
+		}
+	}
+	return p.offsets[shard] + staggeredBatch(p.shards[shard].Replicas, p.batchSize, ordinal)
 }
@@ -20,6 +20,7 @@ import (
 
 func TestRunStaggeredDelete(t *testing.T) {
 	client := fake.NewSimpleClientset()
+	fakeApply(client)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -31,7 +32,7 @@ func TestRunStaggeredDelete(t *testing.T) {
 	}
 	opts := StaggeredDeleteOptions{
 		BatchSize: 1,
-		Interval:  0,
+		Interval:  30 * time.Millisecond,
 	}
 
 	client.PrependReactor("get", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
@@ -88,11 +89,59 @@ func TestRunStaggeredDelete(t *testing.T) {
 		return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "persistentvolumeclaims"}, name)
 	})
 
-	_, latencies, err := RunStaggeredDelete(ctx, client, config, opts, 1*time.Millisecond)
+	// The scale-down timeout bounds each request, so intervals adding up to
+	// more than it must not fail the run.
+	runOpts := RunOptions{PollInterval: 1 * time.Millisecond, Timeouts: Timeouts{ScaleDown: 10 * time.Millisecond}}
+	result, err := RunStaggeredDelete(ctx, client, config, opts, runOpts)
 	if err != nil {
 		t.Fatalf("RunStaggeredDelete error: %v", err)
 	}
-	if len(latencies) != int(config.Replicas) {
-		t.Fatalf("expected %d latencies, got %d", config.Replicas, len(latencies))
+	if len(result.Latencies()) != int(config.Replicas) {
+		t.Fatalf("expected %d latencies, got %d", config.Replicas, len(result.Latencies()))
+	}
+	batches := result.Batches()
+	if len(batches) != 2 || len(result.BatchStarts) != 2 {
+		t.Fatalf("expected 2 batches, got %d summaries and %d starts", len(batches), len(result.BatchStarts))
+	}
+	for _, b := range batches {
+		if b.Count != 1 {
+			t.Fatalf("expected batch %d to contain 1 sample, got %d", b.Batch, b.Count)
+		}
+	}
+}
+
+func TestStaggeredPlanShards(t *testing.T) {
+	config := k8s.StatefulSetConfig{Name: "pvcbench-sts", Replicas: 5, Shards: 2}
+	plan := newStaggeredPlan(config.ShardConfigs(), 2)
+
+	// Shard 0 has 3 replicas (3 -> 1 -> 0), shard 1 has 2 (2 -> 0).
+	want := []struct {
+		shard    string
+		replicas int32
+	}{{"pvcbench-sts-0", 1}, {"pvcbench-sts-0", 0}, {"pvcbench-sts-1", 0}}
+	if len(plan.steps) != len(want) {
+		t.Fatalf("expected %d steps, got %+v", len(want), plan.steps)
+	}
+	var removed int32
+	for i, w := range want {
+		if plan.steps[i].shard.Name != w.shard || plan.steps[i].replicas != w.replicas {
+			t.Fatalf("step %d: expected %s -> %d, got %s -> %d", i, w.shard, w.replicas, plan.steps[i].shard.Name, plan.steps[i].replicas)
+		}
+		removed += plan.steps[i].removed
+	}
+	if removed != 5 {
+		t.Fatalf("expected all 5 replicas removed, got %d", removed)
+	}
+
+	for pvc, batch := range map[string]int{
+		"data-pvcbench-sts-0-2": 0,
+		"data-pvcbench-sts-0-1": 0,
+		"data-pvcbench-sts-0-0": 1,
+		"data-pvcbench-sts-1-1": 2,
+		"data-pvcbench-sts-1-0": 2,
+	} {
+		if got := plan.batchOf(pvc); got != batch {
+			t.Fatalf("batchOf(%s) = %d, want %d", pvc, got, batch)
+		}
 	}
 }
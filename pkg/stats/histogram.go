@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Bucket struct {
+	Low   time.Duration
+	High  time.Duration
+	Count int
+}
+
+// Buckets splits the range [min, max] into n equal-width buckets. The last
+// bucket is closed on both ends so the maximum is always counted.
+func Buckets(latencies []time.Duration, n int) []Bucket {
+	sorted := Sorted(latencies)
+	if len(sorted) == 0 || n <= 0 {
+		return nil
+	}
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	if lo == hi {
+		return []Bucket{{Low: lo, High: hi, Count: len(sorted)}}
+	}
+
+	width := (hi - lo) / time.Duration(n)
+	if width <= 0 {
+		width = 1
+	}
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i].Low = lo + time.Duration(i)*width
+		buckets[i].High = lo + time.Duration(i+1)*width
+	}
+	buckets[n-1].High = hi
+
+	for _, l := range sorted {
+		idx := int((l - lo) / width)
+		if idx >= n {
+			idx = n - 1
+		}
+		buckets[idx].Count++
+	}
+	return buckets
+}
+
+// Histogram renders latencies as horizontal ASCII bars scaled to barWidth.
+func Histogram(latencies []time.Duration, n, barWidth int) string {
+	buckets := Buckets(latencies, n)
+	if len(buckets) == 0 {
+		return ""
+	}
+	maxCount := 0
+	for _, b := range buckets {
+		if b.Count > maxCount {
+			maxCount = b.Count
+		}
+	}
+
+	var sb strings.Builder
+	for _, b := range buckets {
+		bar := 0
+		if maxCount > 0 {
+			bar = b.Count * barWidth / maxCount
+		}
+		if bar == 0 && b.Count > 0 {
+			bar = 1
+		}
+		fmt.Fprintf(&sb, "  %12s - %-12s |%-*s %d\n", round(b.Low), round(b.High), barWidth, strings.Repeat("#", bar), b.Count)
+	}
+	return sb.String()
+}
+
+// CDF renders the latency at each of the given percentiles together with a
+// bar proportional to the cumulative share of samples.
+func CDF(latencies []time.Duration, percentiles []float64, barWidth int) string {
+	sorted := Sorted(latencies)
+	if len(sorted) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, p := range percentiles {
+		bar := int(p / 100 * float64(barWidth))
+		fmt.Fprintf(&sb, "  %6.1f%% <= %-12s |%s\n", p, round(Percentile(sorted, p)), strings.Repeat("=", bar))
+	}
+	return sb.String()
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	}
+	return d
+}
@@ -0,0 +1,161 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+)
+
+const (
+	bootstrapIterations = 1000
+	bootstrapConfidence = 0.95
+	bootstrapSeed       = 42
+)
+
+type Interval struct {
+	Low  time.Duration
+	High time.Duration
+}
+
+type Summary struct {
+	Count      int
+	Min        time.Duration
+	Max        time.Duration
+	Mean       time.Duration
+	StdDev     time.Duration
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	P999       time.Duration
+	Q1         time.Duration
+	Q3         time.Duration
+	LowerFence time.Duration
+	UpperFence time.Duration
+	Outliers   int
+	P50CI      Interval
+	P99CI      Interval
+}
+
+// Sorted returns an ascending copy of latencies, leaving the input untouched.
+func Sorted(latencies []time.Duration) []time.Duration {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+// Percentile returns the p-th percentile (0-100) of an ascending slice using
+// linear interpolation between the closest ranks.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[n-1]
+	}
+	rank := p / 100 * float64(n-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	if lo == hi {
+		return sorted[lo]
+	}
+	frac := rank - float64(lo)
+	return sorted[lo] + time.Duration(math.Round(frac*float64(sorted[hi]-sorted[lo])))
+}
+
+func Mean(latencies []time.Duration) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	var total float64
+	for _, l := range latencies {
+		total += float64(l)
+	}
+	return time.Duration(math.Round(total / float64(len(latencies))))
+}
+
+// StdDev returns the sample standard deviation.
+func StdDev(latencies []time.Duration) time.Duration {
+	n := len(latencies)
+	if n < 2 {
+		return 0
+	}
+	mean := float64(Mean(latencies))
+	var sum float64
+	for _, l := range latencies {
+		d := float64(l) - mean
+		sum += d * d
+	}
+	return time.Duration(math.Round(math.Sqrt(sum / float64(n-1))))
+}
+
+// BootstrapCI estimates a confidence interval for the p-th percentile by
+// resampling with replacement. The generator is seeded so that repeated
+// summaries of the same run print the same interval.
+func BootstrapCI(sorted []time.Duration, p, confidence float64, iterations int) Interval {
+	n := len(sorted)
+	if n == 0 || iterations <= 0 {
+		return Interval{}
+	}
+	rng := rand.New(rand.NewPCG(bootstrapSeed, uint64(n)))
+	estimates := make([]time.Duration, iterations)
+	sample := make([]time.Duration, n)
+	for i := range estimates {
+		for j := range sample {
+			sample[j] = sorted[rng.IntN(n)]
+		}
+		sort.Slice(sample, func(a, b int) bool {
+			return sample[a] < sample[b]
+		})
+		estimates[i] = Percentile(sample, p)
+	}
+	sort.Slice(estimates, func(a, b int) bool {
+		return estimates[a] < estimates[b]
+	})
+	alpha := (1 - confidence) / 2 * 100
+	return Interval{
+		Low:  Percentile(estimates, alpha),
+		High: Percentile(estimates, 100-alpha),
+	}
+}
+
+func Summarize(latencies []time.Duration) Summary {
+	sorted := Sorted(latencies)
+	n := len(sorted)
+	if n == 0 {
+		return Summary{}
+	}
+
+	s := Summary{
+		Count:  n,
+		Min:    sorted[0],
+		Max:    sorted[n-1],
+		Mean:   Mean(sorted),
+		StdDev: StdDev(sorted),
+		P50:    Percentile(sorted, 50),
+		P90:    Percentile(sorted, 90),
+		P99:    Percentile(sorted, 99),
+		P999:   Percentile(sorted, 99.9),
+		Q1:     Percentile(sorted, 25),
+		Q3:     Percentile(sorted, 75),
+	}
+
+	iqr := s.Q3 - s.Q1
+	s.LowerFence = s.Q1 - iqr*3/2
+	s.UpperFence = s.Q3 + iqr*3/2
+	for _, l := range sorted {
+		if l < s.LowerFence || l > s.UpperFence {
+			s.Outliers++
+		}
+	}
+
+	s.P50CI = BootstrapCI(sorted, 50, bootstrapConfidence, bootstrapIterations)
+	s.P99CI = BootstrapCI(sorted, 99, bootstrapConfidence, bootstrapIterations)
+	return s
+}
@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func uniform(n int) []time.Duration {
+	latencies := make([]time.Duration, 0, n)
+	for i := n; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	return latencies
+}
+
+func TestPercentileInterpolates(t *testing.T) {
+	sorted := Sorted(uniform(100))
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 0, want: time.Millisecond},
+		{p: 50, want: 50500 * time.Microsecond},
+		{p: 90, want: 90100 * time.Microsecond},
+		{p: 99, want: 99010 * time.Microsecond},
+		{p: 100, want: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Percentile(sorted, tt.p); got != tt.want {
+			t.Fatalf("p%.1f: expected %s, got %s", tt.p, tt.want, got)
+		}
+	}
+}
+
+func TestPercentileSmallSamples(t *testing.T) {
+	if got := Percentile(nil, 50); got != 0 {
+		t.Fatalf("expected 0 for empty input, got %s", got)
+	}
+	single := []time.Duration{time.Second}
+	if got := Percentile(single, 99); got != time.Second {
+		t.Fatalf("expected single sample, got %s", got)
+	}
+	pair := []time.Duration{time.Second, 3 * time.Second}
+	if got := Percentile(pair, 50); got != 2*time.Second {
+		t.Fatalf("expected midpoint 2s, got %s", got)
+	}
+}
+
+func TestSummarizeUniform(t *testing.T) {
+	s := Summarize(uniform(100))
+
+	if s.Count != 100 || s.Min != time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Fatalf("unexpected count/min/max: %d %s %s", s.Count, s.Min, s.Max)
+	}
+	if s.Mean != 50500*time.Microsecond {
+		t.Fatalf("expected mean 50.5ms, got %s", s.Mean)
+	}
+	// Sample standard deviation of 1..100 is sqrt(841.666...) ~= 29.0115.
+	want := 29.0115 * float64(time.Millisecond)
+	if math.Abs(float64(s.StdDev)-want) > float64(10*time.Microsecond) {
+		t.Fatalf("expected stddev ~29.01ms, got %s", s.StdDev)
+	}
+	if s.Outliers != 0 {
+		t.Fatalf("expected no outliers in a uniform distribution, got %d", s.Outliers)
+	}
+	if s.P50CI.Low > s.P50 || s.P50CI.High < s.P50 {
+		t.Fatalf("expected p50 CI %v to contain %s", s.P50CI, s.P50)
+	}
+	if s.P99CI.Low > s.P99CI.High {
+		t.Fatalf("invalid p99 CI %v", s.P99CI)
+	}
+}
+
+func TestSummarizeDetectsOutliers(t *testing.T) {
+	latencies := uniform(10)
+	latencies = append(latencies, 10*time.Second)
+
+	s := Summarize(latencies)
+	if s.Outliers != 1 {
+		t.Fatalf("expected 1 outlier, got %d (fences %s..%s)", s.Outliers, s.LowerFence, s.UpperFence)
+	}
+}
+
+func TestSummarizeConstant(t *testing.T) {
+	latencies := []time.Duration{time.Second, time.Second, time.Second}
+	s := Summarize(latencies)
+	if s.StdDev != 0 || s.Outliers != 0 {
+		t.Fatalf("expected zero spread, got stddev=%s outliers=%d", s.StdDev, s.Outliers)
+	}
+	if s.P99CI.Low != time.Second || s.P99CI.High != time.Second {
+		t.Fatalf("expected degenerate CI at 1s, got %v", s.P99CI)
+	}
+}
+
+func TestBucketsCountEverySample(t *testing.T) {
+	buckets := Buckets(uniform(100), 10)
+	if len(buckets) != 10 {
+		t.Fatalf("expected 10 buckets, got %d", len(buckets))
+	}
+	total := 0
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total != 100 {
+		t.Fatalf("expected 100 samples across buckets, got %d", total)
+	}
+}
+
+func TestHistogramAndCDF(t *testing.T) {
+	hist := Histogram(uniform(20), 4, 10)
+	if strings.Count(hist, "\n") != 4 || !strings.Contains(hist, "#") {
+		t.Fatalf("unexpected histogram:\n%s", hist)
+	}
+	cdf := CDF(uniform(20), []float64{50, 100}, 10)
+	if !strings.Contains(cdf, "100.0% <= 20ms") {
+		t.Fatalf("unexpected cdf:\n%s", cdf)
+	}
+	if Histogram(nil, 4, 10) != "" || CDF(nil, []float64{50}, 10) != "" {
+		t.Fatalf("expected empty output for no samples")
+	}
+}